@@ -104,6 +104,27 @@ type Performance struct {
 		Prog   int  `json:"prog"`
 		Prov   bool `json:"prov"`
 	} `json:"horde"`
+	Crazyhouse struct {
+		Games  int  `json:"games"`
+		Rating int  `json:"rating"`
+		Rd     int  `json:"rd"`
+		Prog   int  `json:"prog"`
+		Prov   bool `json:"prov"`
+	} `json:"crazyhouse"`
+	Antichess struct {
+		Games  int  `json:"games"`
+		Rating int  `json:"rating"`
+		Rd     int  `json:"rd"`
+		Prog   int  `json:"prog"`
+		Prov   bool `json:"prov"`
+	} `json:"antichess"`
+	ThreeCheck struct {
+		Games  int  `json:"games"`
+		Rating int  `json:"rating"`
+		Rd     int  `json:"rd"`
+		Prog   int  `json:"prog"`
+		Prov   bool `json:"prov"`
+	} `json:"threeCheck"`
 	Puzzle struct {
 		Games  int  `json:"games"`
 		Rating int  `json:"rating"`
@@ -131,6 +152,42 @@ type Performance struct {
 	} `json:"storm"`
 }
 
+// Rating returns the rating of a perf, named by speed or variant key, and
+// whether any game was played in it.
+func (p *Performance) Rating(perf string) (int, bool) {
+	switch perf {
+	case "ultraBullet":
+		return p.UltraBullet.Rating, p.UltraBullet.Games > 0
+	case "bullet":
+		return p.Bullet.Rating, p.Bullet.Games > 0
+	case "blitz":
+		return p.Blitz.Rating, p.Blitz.Games > 0
+	case "rapid":
+		return p.Rapid.Rating, p.Rapid.Games > 0
+	case "classical":
+		return p.Classical.Rating, p.Classical.Games > 0
+	case "correspondence":
+		return p.Correspondence.Rating, p.Correspondence.Games > 0
+	case VariantChess960:
+		return p.Chess960.Rating, p.Chess960.Games > 0
+	case VariantAtomic:
+		return p.Atomic.Rating, p.Atomic.Games > 0
+	case VariantRacingKings:
+		return p.RacingKings.Rating, p.RacingKings.Games > 0
+	case VariantKingOfTheHill:
+		return p.KingOfTheHill.Rating, p.KingOfTheHill.Games > 0
+	case VariantHorde:
+		return p.Horde.Rating, p.Horde.Games > 0
+	case VariantCrazyhouse:
+		return p.Crazyhouse.Rating, p.Crazyhouse.Games > 0
+	case VariantAntichess:
+		return p.Antichess.Rating, p.Antichess.Games > 0
+	case VariantThreeCheck:
+		return p.ThreeCheck.Rating, p.ThreeCheck.Games > 0
+	}
+	return 0, false
+}
+
 type Profile struct {
 	Country    string `json:"country"`
 	Location   string `json:"location"`
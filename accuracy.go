@@ -0,0 +1,310 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+const (
+	initialEvalCp  = 15
+	evalCeilingCp  = 1000
+	winningChances = 0.00368208
+)
+
+const (
+	JudgmentInaccuracy = "Inaccuracy"
+	JudgmentMistake    = "Mistake"
+	JudgmentBlunder    = "Blunder"
+)
+
+var ErrNoAnalysis = errors.New("game has no computer analysis")
+
+// evalCp converts an evaluation from white's point of view to centipawns,
+// counting any forced mate as the ceiling.
+func evalCp(a MoveAnalysis) int {
+	switch {
+	case a.Mate > 0:
+		return evalCeilingCp
+	case a.Mate < 0:
+		return -evalCeilingCp
+	case a.Eval > evalCeilingCp:
+		return evalCeilingCp
+	case a.Eval < -evalCeilingCp:
+		return -evalCeilingCp
+	}
+	return a.Eval
+}
+
+// WinChances maps centipawns to the expected outcome between -1 and 1.
+func WinChances(cp int) float64 {
+	if cp > evalCeilingCp {
+		cp = evalCeilingCp
+	} else if cp < -evalCeilingCp {
+		cp = -evalCeilingCp
+	}
+	return 2/(1+math.Exp(-winningChances*float64(cp))) - 1
+}
+
+// WinPercent is the winning chance of white as a percentage.
+func WinPercent(cp int) float64 {
+	return 50 + 50*WinChances(cp)
+}
+
+// MoveAccuracy rates a move from 0 to 100 by the win percentage the mover
+// had before and after it.
+func MoveAccuracy(before, after float64) float64 {
+	if after >= before {
+		return 100
+	}
+	raw := 103.1668100711649*math.Exp(-0.04354415386753951*(before-after)) - 3.166924740191411 + 1
+	return math.Max(0, math.Min(100, raw))
+}
+
+type MoveQuality struct {
+	Ply        int
+	Color      Color
+	CpLoss     int
+	WinBefore  float64
+	WinAfter   float64
+	Accuracy   float64
+	Judgment   string
+	MateBefore int
+	MateAfter  int
+}
+
+type PlayerAccuracy struct {
+	ACPL         int
+	Accuracy     float64
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+}
+
+type GameAccuracy struct {
+	Moves []MoveQuality
+	White PlayerAccuracy
+	Black PlayerAccuracy
+}
+
+func (g *GameAccuracy) Player(c Color) *PlayerAccuracy {
+	if c == White {
+		return &g.White
+	}
+	return &g.Black
+}
+
+// Judge classifies a move from the evaluations before and after it, using
+// the thresholds Lichess applies to winning chances and its rules for
+// creating, losing and delaying a forced mate.
+func Judge(mover Color, before, after MoveAnalysis) string {
+	sign := 1
+	if mover == Black {
+		sign = -1
+	}
+	mateBefore, mateAfter := sign*before.Mate, sign*after.Mate
+	cpBefore, cpAfter := sign*before.Eval, sign*after.Eval
+
+	switch {
+	case mateBefore == 0 && mateAfter < 0:
+		switch {
+		case cpBefore < -999:
+			return JudgmentInaccuracy
+		case cpBefore < -700:
+			return JudgmentMistake
+		}
+		return JudgmentBlunder
+	case mateBefore > 0 && mateAfter == 0:
+		switch {
+		case cpAfter > 999:
+			return JudgmentInaccuracy
+		case cpAfter > 700:
+			return JudgmentMistake
+		}
+		return JudgmentBlunder
+	case mateBefore > 0 && mateAfter < 0:
+		return JudgmentBlunder
+	case mateBefore != 0 || mateAfter != 0:
+		return ""
+	}
+
+	delta := float64(sign) * (WinChances(before.Eval) - WinChances(after.Eval))
+	switch {
+	case delta >= 0.3:
+		return JudgmentBlunder
+	case delta >= 0.2:
+		return JudgmentMistake
+	case delta >= 0.1:
+		return JudgmentInaccuracy
+	}
+	return ""
+}
+
+func gameStartColor(game *Game) Color {
+	if game.InitialFen != "" {
+		if fields := strings.Fields(game.InitialFen); len(fields) > 1 && fields[1] == "b" {
+			return Black
+		}
+	}
+	return White
+}
+
+// finalMate returns the evaluation of the final position of a game that
+// ends in checkmate.
+func finalMate(game *Game) (MoveAnalysis, bool) {
+	replayed, err := ReplayGame(game)
+	if err != nil || len(replayed.Plies) == 0 || !replayed.Final().IsCheckmate() {
+		return MoveAnalysis{}, false
+	}
+	return evaluate(replayed.Final(), nil), true
+}
+
+// AnalyzeAccuracy computes per move and per player statistics from the
+// evaluations of a game export.
+func AnalyzeAccuracy(game *Game) (*GameAccuracy, error) {
+	if len(game.Analysis) == 0 {
+		return nil, ErrNoAnalysis
+	}
+
+	evals := make([]MoveAnalysis, 0, len(game.Analysis)+2)
+	evals = append(evals, MoveAnalysis{Eval: initialEvalCp})
+	evals = append(evals, game.Analysis...)
+	// The position after the mating move has no eval of its own: score it
+	// as mate rather than trusting a missing or zero entry.
+	if plies := len(strings.Fields(game.Moves)); plies > 0 && len(game.Analysis) >= plies-1 && len(game.Analysis) <= plies {
+		if mate, ok := finalMate(game); ok {
+			evals = append(evals[:plies], mate)
+		}
+	}
+
+	start := gameStartColor(game)
+	result := &GameAccuracy{}
+	for i := 1; i < len(evals); i++ {
+		mover := start
+		if i%2 == 0 {
+			mover = start.Other()
+		}
+		before, after := evalCp(evals[i-1]), evalCp(evals[i])
+		winBefore, winAfter := WinPercent(before), WinPercent(after)
+		loss := before - after
+		if mover == Black {
+			winBefore, winAfter = 100-winBefore, 100-winAfter
+			loss = -loss
+		}
+		if loss < 0 {
+			loss = 0
+		}
+		result.Moves = append(result.Moves, MoveQuality{
+			Ply:        i,
+			Color:      mover,
+			CpLoss:     loss,
+			WinBefore:  winBefore,
+			WinAfter:   winAfter,
+			Accuracy:   MoveAccuracy(winBefore, winAfter),
+			Judgment:   Judge(mover, evals[i-1], evals[i]),
+			MateBefore: evals[i-1].Mate,
+			MateAfter:  evals[i].Mate,
+		})
+	}
+
+	weights := accuracyWeights(evals)
+	for _, c := range []Color{White, Black} {
+		player := result.Player(c)
+		var accuracies, moveWeights []float64
+		totalLoss := 0
+		for i, m := range result.Moves {
+			if m.Color != c {
+				continue
+			}
+			totalLoss += m.CpLoss
+			accuracies = append(accuracies, m.Accuracy)
+			moveWeights = append(moveWeights, weights[i])
+			switch m.Judgment {
+			case JudgmentInaccuracy:
+				player.Inaccuracies++
+			case JudgmentMistake:
+				player.Mistakes++
+			case JudgmentBlunder:
+				player.Blunders++
+			}
+		}
+		if len(accuracies) == 0 {
+			continue
+		}
+		player.ACPL = int(math.Round(float64(totalLoss) / float64(len(accuracies))))
+		player.Accuracy = (weightedMean(accuracies, moveWeights) + harmonicMean(accuracies)) / 2
+	}
+	return result, nil
+}
+
+// accuracyWeights weights each move by the volatility of the win percentage
+// around it, so that moves in sharp positions count more, as Lichess does.
+func accuracyWeights(evals []MoveAnalysis) []float64 {
+	wins := make([]float64, len(evals))
+	for i, e := range evals {
+		wins[i] = WinPercent(evalCp(e))
+	}
+	size := (len(evals) - 1) / 10
+	if size < 2 {
+		size = 2
+	} else if size > 8 {
+		size = 8
+	}
+	if size > len(wins) {
+		size = len(wins)
+	}
+
+	var windows [][]float64
+	for i := 0; i < size-2; i++ {
+		windows = append(windows, wins[:size])
+	}
+	for i := 0; i+size <= len(wins); i++ {
+		windows = append(windows, wins[i:i+size])
+	}
+
+	weights := make([]float64, len(evals)-1)
+	for i := range weights {
+		w := 0.5
+		if i < len(windows) {
+			w = math.Max(0.5, math.Min(12, standardDeviation(windows[i])))
+		}
+		weights[i] = w
+	}
+	return weights
+}
+
+func standardDeviation(xs []float64) float64 {
+	mean := 0.0
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	variance := 0.0
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(variance / float64(len(xs)))
+}
+
+func weightedMean(xs, weights []float64) float64 {
+	sum, total := 0.0, 0.0
+	for i, x := range xs {
+		sum += x * weights[i]
+		total += weights[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+func harmonicMean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		if x <= 0 {
+			return 0
+		}
+		sum += 1 / x
+	}
+	return float64(len(xs)) / sum
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWinPercentAndAccuracy(t *testing.T) {
+	if w := WinPercent(0); w != 50 {
+		t.Errorf("Expected 50%% at equality, got %f", w)
+	}
+	if w := WinPercent(5000); math.Abs(w-WinPercent(1000)) > 1e-9 {
+		t.Errorf("Expected evaluations to be capped at 1000cp")
+	}
+	if a := MoveAccuracy(50, 55); a != 100 {
+		t.Errorf("Expected a move that improves to be 100%% accurate, got %f", a)
+	}
+	if a := MoveAccuracy(60, 40); math.Abs(a-41.02) > 0.01 {
+		t.Errorf("Expected 41.02%% accuracy, got %f", a)
+	}
+}
+
+func TestJudge(t *testing.T) {
+	tests := []struct {
+		mover         Color
+		before, after MoveAnalysis
+		judgment      string
+	}{
+		{White, MoveAnalysis{Eval: 30}, MoveAnalysis{Eval: 10}, ""},
+		{White, MoveAnalysis{Eval: 30}, MoveAnalysis{Eval: -20}, ""},
+		{White, MoveAnalysis{Eval: 30}, MoveAnalysis{Eval: -60}, JudgmentInaccuracy},
+		{Black, MoveAnalysis{Eval: -30}, MoveAnalysis{Eval: 100}, JudgmentMistake},
+		{Black, MoveAnalysis{Eval: 0}, MoveAnalysis{Eval: 300}, JudgmentBlunder},
+		{White, MoveAnalysis{Eval: -800}, MoveAnalysis{Mate: -4}, JudgmentMistake},
+		{White, MoveAnalysis{Eval: -1200}, MoveAnalysis{Mate: -4}, JudgmentInaccuracy},
+		{Black, MoveAnalysis{Eval: 100}, MoveAnalysis{Mate: 2}, JudgmentBlunder},
+		{White, MoveAnalysis{Mate: 3}, MoveAnalysis{Eval: 800}, JudgmentMistake},
+		{White, MoveAnalysis{Mate: 3}, MoveAnalysis{Mate: 5}, ""},
+		{Black, MoveAnalysis{Mate: -2}, MoveAnalysis{Mate: 1}, JudgmentBlunder},
+	}
+	for _, test := range tests {
+		if j := Judge(test.mover, test.before, test.after); j != test.judgment {
+			t.Errorf("%v %+v -> %+v: expected %q, got %q", test.mover, test.before, test.after, test.judgment, j)
+		}
+	}
+}
+
+func TestAnalyzeAccuracy(t *testing.T) {
+	game := Game{Analysis: []MoveAnalysis{{Eval: 20}, {Eval: 60}, {Eval: -300}, {Eval: -280}, {Mate: -3}}}
+	acc, err := AnalyzeAccuracy(&game)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(acc.Moves) != 5 || acc.Moves[2].CpLoss != 360 || acc.Moves[2].Judgment != JudgmentBlunder {
+		t.Errorf("Unexpected moves %+v", acc.Moves)
+	}
+	if acc.White.ACPL != 360 || acc.Black.ACPL != 30 {
+		t.Errorf("Expected ACPL 360/30, got %d/%d", acc.White.ACPL, acc.Black.ACPL)
+	}
+	if acc.White.Blunders != 2 || acc.Black.Blunders != 0 {
+		t.Errorf("Unexpected blunder counts %+v %+v", acc.White, acc.Black)
+	}
+	if acc.White.Accuracy >= acc.Black.Accuracy || acc.Black.Accuracy < 85 || acc.White.Accuracy <= 0 {
+		t.Errorf("Unexpected accuracies %f %f", acc.White.Accuracy, acc.Black.Accuracy)
+	}
+
+	blackFirst := Game{InitialFen: "4k3/8/8/8/8/8/8/4K2R b K - 0 1", Analysis: []MoveAnalysis{{Eval: 900}, {Mate: 1}}}
+	acc, _ = AnalyzeAccuracy(&blackFirst)
+	if acc.Moves[0].Color != Black || acc.Moves[1].Color != White || acc.Moves[0].CpLoss != 885 {
+		t.Errorf("Expected black to move first, got %+v", acc.Moves)
+	}
+
+	if _, err := AnalyzeAccuracy(&Game{}); err != ErrNoAnalysis {
+		t.Errorf("Expected ErrNoAnalysis, got %v", err)
+	}
+}
+
+func TestAnalyzeAccuracyFinalMate(t *testing.T) {
+	pg, err := ParsePgn(scholarsMatePgn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	game := pg.Game()
+	acc, err := AnalyzeAccuracy(game)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if last := acc.Moves[6]; last.Judgment != "" || last.CpLoss != 0 {
+		t.Errorf("Expected the mating move to be fine, got %+v", last)
+	}
+	if acc.White.Blunders != 0 || acc.White.ACPL > 10 {
+		t.Errorf("Unexpected white stats %+v", acc.White)
+	}
+
+	// A zero entry in place of the missing eval is not trusted either.
+	game.Analysis[6] = MoveAnalysis{}
+	if acc, _ := AnalyzeAccuracy(game); acc.Moves[6].Judgment != "" || acc.White.Blunders != 0 {
+		t.Errorf("Expected a zero final entry to be scored as mate, got %+v", acc.Moves[6])
+	}
+	game.Analysis = game.Analysis[:6]
+	if acc, _ := AnalyzeAccuracy(game); len(acc.Moves) != 7 || acc.Moves[6].Judgment != "" {
+		t.Errorf("Expected a missing final entry to be scored as mate, got %+v", acc.Moves)
+	}
+}
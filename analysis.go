@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedVariant = errors.New("variant is not supported by the engine")
+
+type AnalysisOptions struct {
+	Depth    int
+	MoveTime time.Duration
+	Nodes    int64
+	// VariationLength limits the suggested line after a mistake, in plies.
+	VariationLength int
+}
+
+func NewAnalysisOptions() AnalysisOptions {
+	return AnalysisOptions{
+		Depth:           18,
+		VariationLength: 8,
+	}
+}
+
+func (o AnalysisOptions) params() SearchParams {
+	return SearchParams{Depth: o.Depth, MoveTime: o.MoveTime, Nodes: o.Nodes, MultiPV: 1}
+}
+
+// EnginePool hands out a fixed set of running engines to concurrent users.
+type EnginePool struct {
+	engines chan *Engine
+	all     []*Engine
+}
+
+// NewEnginePool starts size engines from the same binary.
+func NewEnginePool(size int, path string, args ...string) (*EnginePool, error) {
+	engines := make([]*Engine, 0, size)
+	for i := 0; i < size; i++ {
+		engine := NewEngine(path, args...)
+		if err := engine.Start(); err != nil {
+			for _, e := range engines {
+				e.Quit()
+			}
+			return nil, err
+		}
+		engines = append(engines, engine)
+	}
+	return NewEnginePoolOf(engines...), nil
+}
+
+// NewEnginePoolOf pools engines that were already configured and started.
+func NewEnginePoolOf(engines ...*Engine) *EnginePool {
+	pool := &EnginePool{engines: make(chan *Engine, len(engines)), all: engines}
+	for _, e := range engines {
+		pool.engines <- e
+	}
+	return pool
+}
+
+func (p *EnginePool) Size() int {
+	return len(p.all)
+}
+
+// Get blocks until an engine is free.
+func (p *EnginePool) Get() *Engine {
+	return <-p.engines
+}
+
+func (p *EnginePool) Put(e *Engine) {
+	p.engines <- e
+}
+
+// SetOption sets an option on every engine of the pool.
+func (p *EnginePool) SetOption(name, value string) error {
+	for _, e := range p.all {
+		if err := e.SetOption(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *EnginePool) Close() error {
+	var first error
+	for _, e := range p.all {
+		if err := e.Quit(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func analysisSupported(game *Game) bool {
+	return game.Variant == "" || game.Variant == VariantStandard || game.Variant == VariantFromPosition
+}
+
+// evaluate converts the engine's view of a position to an evaluation from
+// white's point of view. Positions without legal moves are scored directly.
+func evaluate(pos *Position, result *SearchResult) MoveAnalysis {
+	switch {
+	case pos.IsCheckmate() && pos.Turn == White:
+		return MoveAnalysis{Mate: -1}
+	case pos.IsCheckmate():
+		return MoveAnalysis{Mate: 1}
+	case result == nil || len(result.Lines) == 0:
+		return MoveAnalysis{}
+	}
+	line := result.Lines[0]
+	if pos.Turn == Black {
+		return MoveAnalysis{Eval: -line.Score, Mate: -line.Mate}
+	}
+	return MoveAnalysis{Eval: line.Score, Mate: line.Mate}
+}
+
+// uciLineToSAN converts the start of an engine line to SAN, stopping at the
+// first move that is not legal.
+func uciLineToSAN(pos *Position, pv []string, max int) []string {
+	var sans []string
+	for _, uci := range pv {
+		if max > 0 && len(sans) == max {
+			break
+		}
+		m, err := pos.ParseUCI(uci)
+		if err != nil {
+			break
+		}
+		sans = append(sans, pos.SAN(m))
+		pos = pos.Play(m)
+	}
+	return sans
+}
+
+// AnalyzeGame evaluates every position of the game with the engine and
+// replaces the game's analysis with the result. Moves judged as
+// inaccuracies, mistakes or blunders get the engine's best move and line.
+func AnalyzeGame(engine *Engine, game *Game, opts AnalysisOptions) error {
+	if !analysisSupported(game) {
+		return ErrUnsupportedVariant
+	}
+	replayed, err := ReplayGame(game)
+	if err != nil {
+		return err
+	}
+	if err := engine.NewGame(); err != nil {
+		return err
+	}
+
+	positions := make([]*Position, 0, len(replayed.Plies)+1)
+	positions = append(positions, replayed.Initial)
+	moves := make([]string, 0, len(replayed.Plies))
+	for _, ply := range replayed.Plies {
+		positions = append(positions, ply.Position)
+		moves = append(moves, ply.UCI)
+	}
+
+	results := make([]*SearchResult, len(positions))
+	evals := make([]MoveAnalysis, len(positions))
+	for i, pos := range positions {
+		if pos.HasLegalMoves() {
+			results[i], err = engine.Analyze(game.InitialFen, moves[:i], opts.params(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		evals[i] = evaluate(pos, results[i])
+	}
+
+	analysis := make([]MoveAnalysis, len(replayed.Plies))
+	for i, ply := range replayed.Plies {
+		a := evals[i+1]
+		name := Judge(ply.Color, evals[i], a)
+		if name != "" {
+			comment := name + "."
+			if best := results[i]; best != nil && best.BestMove != "" && best.BestMove != ply.UCI {
+				a.Best = best.BestMove
+				pv := []string{best.BestMove}
+				if len(best.Lines) > 0 && len(best.Lines[0].PV) > 0 && best.Lines[0].PV[0] == best.BestMove {
+					pv = best.Lines[0].PV
+				}
+				if sans := uciLineToSAN(positions[i], pv, opts.VariationLength); len(sans) > 0 {
+					a.Variation = strings.Join(sans, " ")
+					comment += " " + sans[0] + " was best."
+				}
+			}
+			a.Judgment = &Judgment{Name: name, Comment: comment}
+		}
+		analysis[i] = a
+	}
+	game.Analysis = analysis
+	return nil
+}
+
+// AnalysisProgress records the IDs of analyzed games in a file, one per
+// line, so that an interrupted batch can be resumed.
+type AnalysisProgress struct {
+	Path string
+
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+func LoadAnalysisProgress(path string) (*AnalysisProgress, error) {
+	progress := &AnalysisProgress{Path: path, done: make(map[string]bool)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); id != "" {
+			progress.done[id] = true
+		}
+	}
+	return progress, scanner.Err()
+}
+
+func (p *AnalysisProgress) Done(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done[id]
+}
+
+func (p *AnalysisProgress) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.done)
+}
+
+func (p *AnalysisProgress) Mark(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, err := os.OpenFile(p.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, id); err != nil {
+		f.Close()
+		return err
+	}
+	p.done[id] = true
+	return f.Close()
+}
+
+// AnalysisPipeline analyzes games concurrently, one game per engine of the
+// pool, and writes them as annotated PGN in their original order.
+type AnalysisPipeline struct {
+	Pool     *EnginePool
+	Options  AnalysisOptions
+	Progress *AnalysisProgress
+	// OnGame is called after each game is written.
+	OnGame func(game *Game)
+}
+
+type analysisJob struct {
+	index int
+	game  Game
+	err   error
+}
+
+// Run analyzes the games that are not yet recorded in the progress and
+// writes them to w. Games in variants the engine cannot play are skipped.
+// It stops at the first failure and returns the number of games written.
+func (ap *AnalysisPipeline) Run(games []Game, w io.Writer) (int, error) {
+	var todo []Game
+	for _, game := range games {
+		if !analysisSupported(&game) || len(strings.Fields(game.Moves)) == 0 {
+			continue
+		}
+		if ap.Progress != nil && ap.Progress.Done(game.ID) {
+			continue
+		}
+		todo = append(todo, game)
+	}
+
+	jobs := make(chan analysisJob)
+	results := make(chan analysisJob)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	go func() {
+		defer close(jobs)
+		for i, game := range todo {
+			select {
+			case jobs <- analysisJob{index: i, game: game}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < ap.Pool.Size(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				engine := ap.Pool.Get()
+				job.err = AnalyzeGame(engine, &job.game, ap.Options)
+				ap.Pool.Put(engine)
+				results <- job
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	writer := NewPgnWriter(w, NewPgnWriterOptions())
+	pending := make(map[int]analysisJob)
+	next, written := 0, 0
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		stopOnce.Do(func() { close(stop) })
+	}
+	for job := range results {
+		pending[job.index] = job
+		for firstErr == nil {
+			done, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if done.err != nil {
+				fail(fmt.Errorf("%s: %v", done.game.ID, done.err))
+				break
+			}
+			if err := writer.Write(&done.game); err != nil {
+				fail(err)
+				break
+			}
+			if ap.Progress != nil {
+				if err := ap.Progress.Mark(done.game.ID); err != nil {
+					fail(err)
+					break
+				}
+			}
+			written++
+			if ap.OnGame != nil {
+				ap.OnGame(&done.game)
+			}
+		}
+	}
+	return written, firstErr
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const scholarsMate = "e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#"
+
+// scholarsMateScript scores each position of scholarsMate from the side to
+// move, with black's Nf6 allowing mate where g6 holds.
+const scholarsMateScript = `cp 30 e2e4 e7e5
+cp -30 e7e5 g1f3
+cp 30 g1f3 b8c6
+cp 0 b8c6 f1c4
+cp 20 f1c4 g8f6
+cp -20 g7g6 h5f3 g8f6
+mate 1 h5f7
+`
+
+func newScriptedEngine(t *testing.T, bin, script string) *Engine {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	engine := NewEngine(bin, "-script", path)
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return engine
+}
+
+func TestAnalyzeGame(t *testing.T) {
+	engine := newScriptedEngine(t, buildFakeEngine(t), scholarsMateScript)
+	defer engine.Quit()
+
+	game := &Game{ID: "scholar", Variant: VariantStandard, Winner: "white", Status: "mate", Moves: scholarsMate}
+	if err := AnalyzeGame(engine, game, NewAnalysisOptions()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(game.Analysis) != 7 {
+		t.Fatalf("Expected 7 evaluations, got %d", len(game.Analysis))
+	}
+	if a := game.Analysis[0]; a.Eval != 30 || a.Judgment != nil {
+		t.Errorf("Unexpected analysis of e4 %+v", a)
+	}
+	blunder := game.Analysis[5]
+	if blunder.Mate != 1 || blunder.Judgment == nil || blunder.Judgment.Name != JudgmentBlunder {
+		t.Fatalf("Expected Nf6 to be a blunder, got %+v", blunder)
+	}
+	if blunder.Best != "g7g6" || blunder.Variation != "g6 Qf3 Nf6" || blunder.Judgment.Comment != "Blunder. g6 was best." {
+		t.Errorf("Unexpected suggestion %+v %+v", blunder, blunder.Judgment)
+	}
+	if a := game.Analysis[6]; a.Mate != 1 || a.Judgment != nil {
+		t.Errorf("Unexpected analysis of the mating move %+v", a)
+	}
+
+	pgn := NewPgnGame(game).String()
+	for _, want := range []string{"Nf6?? { Blunder. g6 was best. }", "[%eval #1]", "(3... g6 4. Qf3 Nf6)"} {
+		if !strings.Contains(pgn, want) {
+			t.Errorf("Expected %q in annotated PGN:\n%s", want, pgn)
+		}
+	}
+
+	if err := AnalyzeGame(engine, &Game{Variant: VariantAtomic, Moves: "e4"}, NewAnalysisOptions()); err != ErrUnsupportedVariant {
+		t.Errorf("Expected ErrUnsupportedVariant, got %v", err)
+	}
+}
+
+func TestAnalysisPipeline(t *testing.T) {
+	bin := buildFakeEngine(t)
+	pool := NewEnginePoolOf(newScriptedEngine(t, bin, scholarsMateScript), newScriptedEngine(t, bin, scholarsMateScript))
+	defer pool.Close()
+
+	progress, err := LoadAnalysisProgress(filepath.Join(t.TempDir(), "progress"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := progress.Mark("game1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	games := []Game{
+		{ID: "game1", Moves: scholarsMate},
+		{ID: "game2", Moves: scholarsMate},
+		{ID: "game3", Variant: VariantCrazyhouse, Moves: "e4"},
+		{ID: "game4", Moves: scholarsMate},
+		{ID: "game5", Moves: scholarsMate},
+	}
+	var seen []string
+	pipeline := &AnalysisPipeline{Pool: pool, Options: NewAnalysisOptions(), Progress: progress, OnGame: func(g *Game) {
+		seen = append(seen, g.ID)
+	}}
+	var out bytes.Buffer
+	written, err := pipeline.Run(games, &out)
+	if err != nil || written != 3 {
+		t.Fatalf("Expected 3 games written, got %d %v", written, err)
+	}
+	if strings.Join(seen, ",") != "game2,game4,game5" {
+		t.Errorf("Expected games in order, got %v", seen)
+	}
+	if strings.Count(out.String(), "Nf6??") != 3 || strings.Contains(out.String(), "/game1\"") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	resumed, err := LoadAnalysisProgress(progress.Path)
+	if err != nil || resumed.Len() != 4 || !resumed.Done("game5") {
+		t.Fatalf("Expected progress to be saved, got %v", err)
+	}
+	pipeline.Progress = resumed
+	if written, err := pipeline.Run(games, &out); err != nil || written != 0 {
+		t.Errorf("Expected nothing left to analyze, got %d %v", written, err)
+	}
+
+	games = append(games, Game{ID: "broken", Moves: "e4 e4"}, Game{ID: "game6", Moves: scholarsMate})
+	if _, err := pipeline.Run(games, &out); err == nil || !strings.HasPrefix(err.Error(), "broken:") {
+		t.Errorf("Expected an error for the broken game, got %v", err)
+	}
+	if resumed.Done("game6") {
+		t.Errorf("Expected games after a failure not to be written")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const archiveExt = ".ndjson"
+
+var ErrInvalidUsername = errors.New("invalid username")
+
+// usernamePattern matches the characters Lichess allows in usernames, which
+// keeps archive paths inside the archive directory.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// GameStreamer is implemented by Client and lets an Archive sync from any
+// source of exported games.
+type GameStreamer interface {
+	StreamUserGames(username string, params UserGamesParam, fn func(*Game) error) error
+}
+
+// Archive stores the games of each user as newline delimited JSON, one file
+// per user, in a single directory.
+type Archive struct {
+	Dir string
+}
+
+type ArchiveState struct {
+	Games         int
+	LastCreatedAt int64
+	ids           map[string]bool
+	size          int64
+}
+
+func NewArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{Dir: dir}, nil
+}
+
+func (a *Archive) Path(username string) (string, error) {
+	if !usernamePattern.MatchString(username) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidUsername, username)
+	}
+	return filepath.Join(a.Dir, strings.ToLower(username)+archiveExt), nil
+}
+
+func (a *Archive) Users() ([]string, error) {
+	entries, err := os.ReadDir(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), archiveExt) {
+			users = append(users, strings.TrimSuffix(e.Name(), archiveExt))
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// Each calls fn for every stored game of the user in the order they were
+// archived. A trailing line left incomplete by an interrupted sync is ignored.
+func (a *Archive) Each(username string, fn func(*Game) error) error {
+	_, err := a.scan(username, fn)
+	return err
+}
+
+func (a *Archive) Games(username string) ([]Game, error) {
+	games := make([]Game, 0)
+	err := a.Each(username, func(game *Game) error {
+		games = append(games, *game)
+		return nil
+	})
+	return games, err
+}
+
+func (a *Archive) State(username string) (*ArchiveState, error) {
+	return a.scan(username, nil)
+}
+
+func (a *Archive) scan(username string, fn func(*Game) error) (*ArchiveState, error) {
+	path, err := a.Path(username)
+	if err != nil {
+		return nil, err
+	}
+	state := &ArchiveState{ids: make(map[string]bool)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return state, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var game Game
+		if err := json.Unmarshal(line, &game); err != nil {
+			return nil, err
+		}
+		state.size += int64(len(line))
+		if state.ids[game.ID] {
+			continue
+		}
+		state.ids[game.ID] = true
+		state.Games++
+		if game.CreatedAt > state.LastCreatedAt {
+			state.LastCreatedAt = game.CreatedAt
+		}
+		if fn != nil {
+			if err := fn(&game); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Sync downloads the games of username played since the last archived game
+// and appends the ones not stored yet. It returns the number of new games.
+// Games are requested oldest first so an interrupted sync resumes where it
+// stopped.
+func (a *Archive) Sync(source GameStreamer, username string, params UserGamesParam) (int, error) {
+	path, err := a.Path(username)
+	if err != nil {
+		return 0, err
+	}
+	state, err := a.State(username)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(state.size); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(state.size, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	if state.LastCreatedAt > params.Since {
+		params.Since = state.LastCreatedAt
+	}
+	params.Ascending = true
+
+	added := 0
+	w := bufio.NewWriter(f)
+	err = source.StreamUserGames(username, params, func(game *Game) error {
+		if game.ID == "" || state.ids[game.ID] {
+			return nil
+		}
+		data, err := json.Marshal(game)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		state.ids[game.ID] = true
+		added++
+		return w.Flush()
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return added, err
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeGameStreamer struct {
+	games  []Game
+	params []UserGamesParam
+}
+
+func (f *fakeGameStreamer) StreamUserGames(username string, params UserGamesParam, fn func(*Game) error) error {
+	f.params = append(f.params, params)
+	for i := range f.games {
+		if f.games[i].CreatedAt < params.Since {
+			continue
+		}
+		if err := fn(&f.games[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestArchiveSync(t *testing.T) {
+	archive, err := NewArchive(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source := &fakeGameStreamer{games: []Game{
+		{ID: "game1", CreatedAt: 1000, Moves: "e4 e5"},
+		{ID: "game2", CreatedAt: 2000, Moves: "d4 d5"},
+	}}
+
+	added, err := archive.Sync(source, "Alice", NewUserGamesParam())
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 new games, got %d (%v)", added, err)
+	}
+
+	source.games = append(source.games, Game{ID: "game3", CreatedAt: 2000}, Game{ID: "game4", CreatedAt: 3000})
+	added, err = archive.Sync(source, "alice", NewUserGamesParam())
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 more games, got %d (%v)", added, err)
+	}
+	if since := source.params[1].Since; since != 2000 || !source.params[1].Ascending {
+		t.Errorf("Expected to resume from the last game, got since=%d", since)
+	}
+
+	games, err := archive.Games("alice")
+	if err != nil || len(games) != 4 || games[0].Moves != "e4 e5" || games[3].ID != "game4" {
+		t.Errorf("Unexpected archived games %+v (%v)", games, err)
+	}
+	if users, _ := archive.Users(); len(users) != 1 || users[0] != "alice" {
+		t.Errorf("Unexpected users %v", users)
+	}
+}
+
+func TestArchiveInterruptedWrite(t *testing.T) {
+	archive, _ := NewArchive(t.TempDir())
+	content := `{"id":"game1","createdAt":1000}` + "\n" + `{"id":"gam`
+	path, err := archive.Path("bob")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	state, err := archive.State("bob")
+	if err != nil || state.Games != 1 || state.LastCreatedAt != 1000 {
+		t.Fatalf("Unexpected state %+v (%v)", state, err)
+	}
+
+	source := &fakeGameStreamer{games: []Game{{ID: "game1", CreatedAt: 1000}, {ID: "game2", CreatedAt: 1500}}}
+	if added, err := archive.Sync(source, "bob", NewUserGamesParam()); err != nil || added != 1 {
+		t.Fatalf("Expected 1 new game, got %d (%v)", added, err)
+	}
+	if games, err := archive.Games("bob"); err != nil || len(games) != 2 || games[1].ID != "game2" {
+		t.Errorf("Unexpected archived games %+v (%v)", games, err)
+	}
+}
+
+func TestStreamUserGames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/games/user/alice" || r.URL.Query().Get("since") != "1234" || r.URL.Query().Get("sort") != "dateAsc" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "{\"id\":\"game%d\",\"createdAt\":%d}\n", i, 1234+i)
+		}
+	}))
+	defer server.Close()
+
+	c := Client{HttpClient: server.Client(), BaseURL: server.URL}
+	params := NewUserGamesParam()
+	params.Since = 1234
+	params.Ascending = true
+	games, err := c.ExportUserGames("alice", params)
+	if err != nil || len(games) != 3 || games[2].ID != "game3" {
+		t.Errorf("Unexpected games %+v (%v)", games, err)
+	}
+}
+
+func TestArchiveInvalidUsername(t *testing.T) {
+	archive, _ := NewArchive(t.TempDir())
+	for _, username := range []string{"", "../../etc/x", "bob/alice", "bob.ndjson", "bob alice"} {
+		if _, err := archive.Path(username); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: expected ErrInvalidUsername, got %v", username, err)
+		}
+		if _, err := archive.Games(username); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: expected Games to fail, got %v", username, err)
+		}
+		if _, err := archive.Sync(&fakeGameStreamer{}, username, NewUserGamesParam()); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("%q: expected Sync to fail, got %v", username, err)
+		}
+	}
+	if path, err := archive.Path("Bob_the-2nd"); err != nil || filepath.Dir(path) != archive.Dir {
+		t.Errorf("Unexpected path %q (%v)", path, err)
+	}
+}
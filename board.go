@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	GameEventFull         = "gameFull"
+	GameEventState        = "gameState"
+	GameEventChatLine     = "chatLine"
+	GameEventOpponentGone = "opponentGone"
+)
+
+type BoardPlayer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Rating      int    `json:"rating"`
+	Title       string `json:"title,omitempty"`
+	Provisional bool   `json:"provisional,omitempty"`
+	AiLevel     int    `json:"aiLevel,omitempty"`
+}
+
+// GameState is the changing part of a game being played. Moves are in UCI
+// notation and times in milliseconds.
+type GameState struct {
+	Moves     string `json:"moves"`
+	WTime     int64  `json:"wtime"`
+	BTime     int64  `json:"btime"`
+	WInc      int64  `json:"winc"`
+	BInc      int64  `json:"binc"`
+	Status    string `json:"status"`
+	Winner    string `json:"winner,omitempty"`
+	WDraw     bool   `json:"wdraw,omitempty"`
+	BDraw     bool   `json:"bdraw,omitempty"`
+	WTakeback bool   `json:"wtakeback,omitempty"`
+	BTakeback bool   `json:"btakeback,omitempty"`
+}
+
+func (s *GameState) MoveList() []string {
+	return strings.Fields(s.Moves)
+}
+
+// Finished reports whether the game is over.
+func (s *GameState) Finished() bool {
+	return s.Status != "" && s.Status != "created" && s.Status != "started"
+}
+
+func (s *GameState) Time(c Color) time.Duration {
+	if c == Black {
+		return time.Duration(s.BTime) * time.Millisecond
+	}
+	return time.Duration(s.WTime) * time.Millisecond
+}
+
+func (s *GameState) Increment(c Color) time.Duration {
+	if c == Black {
+		return time.Duration(s.BInc) * time.Millisecond
+	}
+	return time.Duration(s.WInc) * time.Millisecond
+}
+
+// DrawOffered reports whether the player of the color offers a draw.
+func (s *GameState) DrawOffered(c Color) bool {
+	if c == Black {
+		return s.BDraw
+	}
+	return s.WDraw
+}
+
+func (s *GameState) TakebackProposed(c Color) bool {
+	if c == Black {
+		return s.BTakeback
+	}
+	return s.WTakeback
+}
+
+type GameFull struct {
+	ID      string       `json:"id"`
+	Rated   bool         `json:"rated"`
+	Variant EventVariant `json:"variant"`
+	Clock   *struct {
+		Initial   int64 `json:"initial"`
+		Increment int64 `json:"increment"`
+	} `json:"clock,omitempty"`
+	Speed string `json:"speed"`
+	Perf  struct {
+		Name string `json:"name"`
+	} `json:"perf"`
+	CreatedAt    int64       `json:"createdAt"`
+	White        BoardPlayer `json:"white"`
+	Black        BoardPlayer `json:"black"`
+	InitialFen   string      `json:"initialFen"`
+	State        GameState   `json:"state"`
+	DaysPerTurn  int         `json:"daysPerTurn,omitempty"`
+	TournamentID string      `json:"tournamentId,omitempty"`
+}
+
+// Player returns the player of the color.
+func (g *GameFull) Player(c Color) BoardPlayer {
+	if c == Black {
+		return g.Black
+	}
+	return g.White
+}
+
+// ColorOf returns the color played by the user, and false if the user does
+// not play in the game.
+func (g *GameFull) ColorOf(userID string) (Color, bool) {
+	switch strings.ToLower(userID) {
+	case g.White.ID:
+		return White, true
+	case g.Black.ID:
+		return Black, true
+	}
+	return White, false
+}
+
+type ChatLine struct {
+	Room     string `json:"room"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+}
+
+type OpponentGone struct {
+	Gone              bool `json:"gone"`
+	ClaimWinInSeconds int  `json:"claimWinInSeconds,omitempty"`
+}
+
+// GameStreamEvent is a message of a game stream. Exactly one of the
+// pointers is set, depending on Type.
+type GameStreamEvent struct {
+	Type         string
+	Full         *GameFull
+	State        *GameState
+	Chat         *ChatLine
+	OpponentGone *OpponentGone
+}
+
+func parseGameStreamEvent(line []byte) (*GameStreamEvent, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &head); err != nil {
+		return nil, err
+	}
+	event := &GameStreamEvent{Type: head.Type}
+	var dest interface{}
+	switch head.Type {
+	case GameEventFull:
+		event.Full = &GameFull{}
+		dest = event.Full
+	case GameEventState:
+		event.State = &GameState{}
+		dest = event.State
+	case GameEventChatLine:
+		event.Chat = &ChatLine{}
+		dest = event.Chat
+	case GameEventOpponentGone:
+		event.OpponentGone = &OpponentGone{}
+		dest = event.OpponentGone
+	default:
+		return event, nil
+	}
+	return event, json.Unmarshal(line, dest)
+}
+
+// PlayAPI sends the moves and actions of the account in its games. The
+// board and bot APIs share it with a different path prefix.
+type PlayAPI struct {
+	Client *Client
+	Prefix string
+}
+
+// Board returns the API to play with a physical or third-party board.
+func (c *Client) Board() *PlayAPI {
+	return &PlayAPI{Client: c, Prefix: "/api/board"}
+}
+
+func (c *Client) newPostRequest(endPoint string, values url.Values) (*http.Request, error) {
+	params := c.DefaultRequestParams()
+	params.Method = "POST"
+	params.ContentType = "application/x-www-form-urlencoded"
+	if values != nil {
+		params.QueryValues = values
+	}
+	return c.NewRequest(c.baseURL()+endPoint, params)
+}
+
+func (c *Client) post(endPoint string, values url.Values) error {
+	req, err := c.newPostRequest(endPoint, values)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (a *PlayAPI) gamePath(gameID string, action ...string) string {
+	return a.Prefix + "/game/" + url.PathEscape(gameID) + "/" + strings.Join(action, "/")
+}
+
+// StreamGame follows the state of a game, starting with a gameFull event.
+// The stream ends after the event that reports the end of the game.
+func (a *PlayAPI) StreamGame(gameID string, opts StreamOptions, fn func(*GameStreamEvent) error) error {
+	endPoint := a.Prefix + "/game/stream/" + url.PathEscape(gameID)
+	return a.Client.stream(endPoint, opts, func(line []byte) error {
+		event, err := parseGameStreamEvent(line)
+		if err != nil {
+			return &StreamLineError{Line: string(line), Err: err}
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+		if (event.Full != nil && event.Full.State.Finished()) || (event.State != nil && event.State.Finished()) {
+			return ErrStreamDone
+		}
+		return nil
+	})
+}
+
+// Move plays a move given in UCI notation, optionally offering or
+// accepting a draw with it.
+func (a *PlayAPI) Move(gameID, move string, offeringDraw bool) error {
+	endPoint := a.gamePath(gameID, "move", url.PathEscape(move))
+	if offeringDraw {
+		endPoint += "?offeringDraw=true"
+	}
+	return a.Client.post(endPoint, nil)
+}
+
+func (a *PlayAPI) Resign(gameID string) error {
+	return a.Client.post(a.gamePath(gameID, "resign"), nil)
+}
+
+func (a *PlayAPI) Abort(gameID string) error {
+	return a.Client.post(a.gamePath(gameID, "abort"), nil)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// HandleDraw offers or accepts a draw with yes and declines one with no.
+func (a *PlayAPI) HandleDraw(gameID string, accept bool) error {
+	return a.Client.post(a.gamePath(gameID, "draw", yesNo(accept)), nil)
+}
+
+// HandleTakeback proposes or accepts a takeback with yes and declines one
+// with no.
+func (a *PlayAPI) HandleTakeback(gameID string, accept bool) error {
+	return a.Client.post(a.gamePath(gameID, "takeback", yesNo(accept)), nil)
+}
+
+// ClaimVictory wins the game after the opponent left it.
+func (a *PlayAPI) ClaimVictory(gameID string) error {
+	return a.Client.post(a.gamePath(gameID, "claim-victory"), nil)
+}
+
+const (
+	ChatRoomPlayer    = "player"
+	ChatRoomSpectator = "spectator"
+)
+
+func (a *PlayAPI) Chat(gameID, room, text string) error {
+	return a.Client.post(a.gamePath(gameID, "chat"), url.Values{"room": {room}, "text": {text}})
+}
+
+// GetChat returns the messages of the player room of a game.
+func (a *PlayAPI) GetChat(gameID string) ([]ChatLine, error) {
+	req, err := a.Client.NewRequest(a.Client.baseURL()+a.gamePath(gameID, "chat"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.Client.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var messages []struct {
+		User string `json:"user"`
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, err
+	}
+	lines := make([]ChatLine, 0, len(messages))
+	for _, m := range messages {
+		lines = append(lines, ChatLine{Room: ChatRoomPlayer, Username: m.User, Text: m.Text})
+	}
+	return lines, nil
+}
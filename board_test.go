@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gameFullEvent = `{"type":"gameFull","id":"5IrD6Gzz","rated":true,"variant":{"key":"standard","name":"Standard","short":"Std"},"clock":{"initial":1200000,"increment":10000},"speed":"classical","perf":{"name":"Classical"},"createdAt":1523825103562,"white":{"id":"lovlas","name":"lovlas","provisional":false,"rating":2500,"title":"IM"},"black":{"id":"leela","name":"leela","rating":2390,"title":null},"initialFen":"startpos","state":{"type":"gameState","moves":"e2e4 c7c5 f2f4","wtime":7598040,"btime":8395220,"winc":10000,"binc":10000,"status":"started"}}`
+
+func TestStreamGame(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/board/game/stream/5IrD6Gzz" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, gameFullEvent)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, `{"type":"chatLine","room":"player","username":"leela","text":"Good luck"}`)
+		fmt.Fprintln(w, `{"type":"gameState","moves":"e2e4 c7c5 f2f4 d7d6","wtime":7598040,"btime":8390000,"winc":10000,"binc":10000,"status":"started","bdraw":true}`)
+		fmt.Fprintln(w, `{"type":"opponentGone","gone":true,"claimWinInSeconds":8}`)
+		fmt.Fprintln(w, `{"type":"gameState","moves":"e2e4 c7c5 f2f4 d7d6","wtime":7598040,"btime":8390000,"winc":10000,"binc":10000,"status":"resign","winner":"white"}`)
+		fmt.Fprintln(w, `{"type":"chatLine","room":"spectator","username":"someone","text":"after the end"}`)
+	}))
+	defer server.Close()
+
+	c := &Client{HttpClient: server.Client(), BaseURL: server.URL}
+	var events []*GameStreamEvent
+	err := c.Board().StreamGame("5IrD6Gzz", NewStreamOptions(), func(e *GameStreamEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("Expected the stream to end with the game after 5 events, got %d", len(events))
+	}
+
+	full := events[0].Full
+	if events[0].Type != GameEventFull || full.White.Title != "IM" || full.Clock.Increment != 10000 || full.State.Status != "started" {
+		t.Errorf("Unexpected gameFull %+v", events[0])
+	}
+	if color, ok := full.ColorOf("Leela"); !ok || color != Black {
+		t.Errorf("Expected leela to play black")
+	}
+	if moves := full.State.MoveList(); len(moves) != 3 || full.State.Time(Black) != 8395220*time.Millisecond {
+		t.Errorf("Unexpected state %+v", full.State)
+	}
+	if chat := events[1].Chat; chat == nil || chat.Username != "leela" || chat.Text != "Good luck" {
+		t.Errorf("Unexpected chat %+v", events[1])
+	}
+	if state := events[2].State; state == nil || !state.DrawOffered(Black) || state.DrawOffered(White) || state.Finished() {
+		t.Errorf("Unexpected state %+v", events[2])
+	}
+	if gone := events[3].OpponentGone; gone == nil || !gone.Gone || gone.ClaimWinInSeconds != 8 {
+		t.Errorf("Unexpected opponentGone %+v", events[3])
+	}
+	if state := events[4].State; !state.Finished() || state.Winner != "white" {
+		t.Errorf("Unexpected final state %+v", events[4])
+	}
+}
+
+func TestBoardActions(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/move/e7e5"):
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"Not your turn, or game already over"}`)
+		case r.Method == "GET":
+			fmt.Fprint(w, `[{"text":"Takeback sent","user":"lichess"},{"text":"hi","user":"alice"}]`)
+		default:
+			fmt.Fprint(w, `{"ok":true}`)
+		}
+	}))
+	defer server.Close()
+
+	board := (&Client{Token: "secret", HttpClient: server.Client(), BaseURL: server.URL}).Board()
+	for _, err := range []error{
+		board.Move("abc", "e2e4", false),
+		board.Move("abc", "e7e8q", true),
+		board.Resign("abc"),
+		board.Abort("abc"),
+		board.HandleDraw("abc", true),
+		board.HandleTakeback("abc", false),
+		board.ClaimVictory("abc"),
+		board.Chat("abc", ChatRoomPlayer, "good game"),
+	} {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	expected := []string{
+		"POST /api/board/game/abc/move/e2e4 ",
+		"POST /api/board/game/abc/move/e7e8q?offeringDraw=true ",
+		"POST /api/board/game/abc/resign ",
+		"POST /api/board/game/abc/abort ",
+		"POST /api/board/game/abc/draw/yes ",
+		"POST /api/board/game/abc/takeback/no ",
+		"POST /api/board/game/abc/claim-victory ",
+		"POST /api/board/game/abc/chat room=player&text=good+game",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+
+	err := board.Move("abc", "e7e5", false)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Not your turn, or game already over" {
+		t.Errorf("Expected the error of the move, got %v", err)
+	}
+
+	lines, err := board.GetChat("abc")
+	if err != nil || len(lines) != 2 || lines[1].Text != "hi" || lines[1].Username != "alice" {
+		t.Errorf("Unexpected chat %+v (%v)", lines, err)
+	}
+}
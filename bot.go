@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Bot returns the API to play as a bot account. Its games are streamed with
+// the same events as board games.
+func (c *Client) Bot() *PlayAPI {
+	return &PlayAPI{Client: c, Prefix: "/api/bot"}
+}
+
+// UpgradeToBot turns the account of the token into a bot account. This
+// cannot be undone and only works for accounts that have not played games.
+func (c *Client) UpgradeToBot() error {
+	return c.post("/api/bot/account/upgrade", nil)
+}
+
+// StreamOnlineBots passes the bots that are online to fn, up to nb of them
+// or all if nb is 0.
+func (c *Client) StreamOnlineBots(nb int, fn func(*Account) error) error {
+	params := c.DefaultRequestParams()
+	params.Accept = "application/x-ndjson"
+	uri := c.baseURL() + "/api/bot/online"
+	if nb > 0 {
+		uri += "?nb=" + strconv.Itoa(nb)
+	}
+	req, err := c.NewRequest(uri, params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var bot Account
+		if err := decoder.Decode(&bot); err != nil {
+			return err
+		}
+		if err := fn(&bot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) GetOnlineBots(nb int) ([]Account, error) {
+	bots := make([]Account, 0)
+	err := c.StreamOnlineBots(nb, func(bot *Account) error {
+		bots = append(bots, *bot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bots, nil
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BotGame is what a MoveChooser knows about a game when it has to move.
+type BotGame struct {
+	ID         string
+	Color      Color
+	Variant    string
+	InitialFen string
+	Full       *GameFull
+	State      *GameState
+	// Moves are the moves played so far, in UCI notation.
+	Moves    []string
+	Position *Position
+	// Budget is the time the bot should spend on this move.
+	Budget time.Duration
+}
+
+type BotMove struct {
+	Move      string
+	OfferDraw bool
+	Resign    bool
+}
+
+// MoveChooser decides the moves of a bot. It is called from one goroutine
+// per game, so implementations shared between games must be safe for
+// concurrent use.
+type MoveChooser interface {
+	ChooseMove(game *BotGame) (BotMove, error)
+}
+
+type MoveChooserFunc func(game *BotGame) (BotMove, error)
+
+func (f MoveChooserFunc) ChooseMove(game *BotGame) (BotMove, error) {
+	return f(game)
+}
+
+// EngineMoveChooser plays the best move of the engines of a pool, searching
+// for the budget of each move. The engine is set up for the game's variant
+// first; variants it cannot play make the bot resign. After other failures
+// the bot asks again, then falls back to a legal move.
+type EngineMoveChooser struct {
+	Pool *EnginePool
+}
+
+func (c *EngineMoveChooser) ChooseMove(game *BotGame) (BotMove, error) {
+	engine := c.Pool.Get()
+	defer c.Pool.Put(engine)
+	if err := engine.SetVariant(game.Variant); err != nil {
+		return BotMove{}, err
+	}
+	result, err := engine.Analyze(game.InitialFen, game.Moves, SearchParams{MoveTime: game.Budget}, nil)
+	if err != nil {
+		return BotMove{}, err
+	}
+	return BotMove{Move: result.BestMove}, nil
+}
+
+type BotOptions struct {
+	// MaxGames limits the number of games played at once. Challenges above
+	// the limit are declined.
+	MaxGames int
+	Stream   StreamOptions
+	// MoveOverhead is kept off every move budget for the network lag.
+	MoveOverhead time.Duration
+	MinMoveTime  time.Duration
+	MaxMoveTime  time.Duration
+	// MovesToGo is the number of moves the remaining time is split over.
+	MovesToGo int
+	// MoveRetries is how often a rejected or failed move is sent again.
+	MoveRetries int
+	Logf        func(format string, args ...interface{})
+}
+
+func NewBotOptions() BotOptions {
+	return BotOptions{
+		MaxGames:     2,
+		Stream:       NewStreamOptions(),
+		MoveOverhead: 300 * time.Millisecond,
+		MinMoveTime:  50 * time.Millisecond,
+		MaxMoveTime:  30 * time.Second,
+		MovesToGo:    30,
+		MoveRetries:  2,
+	}
+}
+
+// MoveBudget splits the remaining time over the moves to go, spending most
+// of the increment, and never uses more than half of what is left.
+// Without a clock the maximum move time is used.
+func (o BotOptions) MoveBudget(remaining, increment time.Duration) time.Duration {
+	if remaining <= 0 {
+		return o.MaxMoveTime
+	}
+	movesToGo := o.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = 1
+	}
+	budget := remaining/time.Duration(movesToGo) + increment*3/4 - o.MoveOverhead
+	if limit := remaining/2 - o.MoveOverhead; budget > limit {
+		budget = limit
+	}
+	if o.MaxMoveTime > 0 && budget > o.MaxMoveTime {
+		budget = o.MaxMoveTime
+	}
+	if budget < o.MinMoveTime {
+		budget = o.MinMoveTime
+	}
+	return budget
+}
+
+// BotRunner plays as a bot account: it follows the event stream, accepts
+// challenges up to the game limit and plays each game in its own goroutine
+// with the moves of the chooser.
+type BotRunner struct {
+	Client  *Client
+	Chooser MoveChooser
+	Options BotOptions
+	// Policy decides which challenges to accept. Without one all
+	// challenges are accepted up to the game limit.
+	Policy *ChallengePolicy
+	// OnEvent is called with every event of the account, after the runner
+	// handled it.
+	OnEvent func(*Event)
+
+	mu       sync.Mutex
+	games    map[string]bool
+	pending  map[string]bool
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	kill     chan struct{}
+	stopOnce sync.Once
+	killOnce sync.Once
+}
+
+func NewBotRunner(client *Client, chooser MoveChooser) *BotRunner {
+	return &BotRunner{
+		Client:  client,
+		Chooser: chooser,
+		Options: NewBotOptions(),
+		games:   make(map[string]bool),
+		pending: make(map[string]bool),
+		stop:    make(chan struct{}),
+		kill:    make(chan struct{}),
+	}
+}
+
+func (b *BotRunner) logf(format string, args ...interface{}) {
+	if b.Options.Logf != nil {
+		b.Options.Logf(format, args...)
+	}
+}
+
+// Run plays until Shutdown or Stop is called, or the event stream fails,
+// and returns once all games it plays are over.
+func (b *BotRunner) Run() error {
+	opts := b.Options.Stream
+	opts.Stop = b.stop
+	err := b.Client.StreamEvents(opts, b.handleEvent)
+	if err != nil {
+		b.Stop()
+	}
+	b.wg.Wait()
+	return err
+}
+
+// Shutdown stops accepting challenges and lets the games in progress
+// finish.
+func (b *BotRunner) Shutdown() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+// Stop shuts down without waiting for the games in progress, which are
+// left without resigning.
+func (b *BotRunner) Stop() {
+	b.Shutdown()
+	b.killOnce.Do(func() { close(b.kill) })
+}
+
+// Playing returns the number of games in progress.
+func (b *BotRunner) Playing() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.games)
+}
+
+func (b *BotRunner) handleEvent(event *Event) error {
+	switch event.Type {
+	case EventChallenge:
+		if event.Challenge != nil && event.Challenge.Direction != "out" {
+			b.handleChallenge(event.Challenge)
+		}
+	case EventChallengeCanceled, EventChallengeDeclined:
+		if event.Challenge != nil {
+			b.mu.Lock()
+			delete(b.pending, event.Challenge.ID)
+			b.mu.Unlock()
+		}
+	case EventGameStart:
+		if event.Game != nil {
+			b.startGame(event.Game)
+		}
+	}
+	if b.OnEvent != nil {
+		b.OnEvent(event)
+	}
+	return nil
+}
+
+func (b *BotRunner) handleChallenge(challenge *Challenge) {
+	b.mu.Lock()
+	playing := len(b.games) + len(b.pending)
+	reason := ""
+	switch {
+	case playing >= b.Options.MaxGames || stopped(b.stop):
+		reason = DeclineLater
+	case b.Policy != nil:
+		reason = b.Policy.DeclineReason(challenge, playing)
+	}
+	if reason == "" {
+		b.pending[challenge.ID] = true
+	}
+	b.mu.Unlock()
+
+	if reason != "" {
+		b.logf("declining challenge %s: %s", challenge.ID, reason)
+		if err := b.Client.DeclineChallenge(challenge.ID, reason); err != nil {
+			b.logf("declining challenge %s: %v", challenge.ID, err)
+		}
+		return
+	}
+	if err := b.Client.AcceptChallenge(challenge.ID); err != nil {
+		b.logf("accepting challenge %s: %v", challenge.ID, err)
+		b.mu.Lock()
+		delete(b.pending, challenge.ID)
+		b.mu.Unlock()
+	}
+}
+
+func (b *BotRunner) startGame(info *GameEventInfo) {
+	id := info.GameID
+	if id == "" {
+		id = info.ID
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, id)
+	if b.games[id] {
+		return
+	}
+	b.games[id] = true
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		if err := b.playGame(id, info.Color); err != nil {
+			b.logf("game %s: %v", id, err)
+		}
+		b.mu.Lock()
+		delete(b.games, id)
+		b.mu.Unlock()
+	}()
+}
+
+type botGameRunner struct {
+	runner *BotRunner
+	api    *PlayAPI
+	color  string
+	game   *BotGame
+	moved  int
+	claim  *time.Timer
+}
+
+func (b *BotRunner) playGame(id, color string) error {
+	g := &botGameRunner{runner: b, api: b.Client.Bot(), color: color, moved: -1}
+	opts := b.Options.Stream
+	opts.Stop = b.kill
+	err := g.api.StreamGame(id, opts, g.handle)
+	if g.claim != nil {
+		g.claim.Stop()
+	}
+	return err
+}
+
+func (g *botGameRunner) handle(event *GameStreamEvent) error {
+	switch event.Type {
+	case GameEventFull:
+		game, err := newBotGame(event.Full, g.color, g.runner.Client)
+		if err != nil {
+			return err
+		}
+		g.game = game
+		return g.update(&event.Full.State)
+	case GameEventState:
+		if g.game == nil {
+			return errors.New("game state before the full game")
+		}
+		return g.update(event.State)
+	case GameEventOpponentGone:
+		g.opponentGone(event.OpponentGone)
+	}
+	return nil
+}
+
+// newBotGame sets up a game from its first event. The color comes from the
+// gameStart event and is looked up from the account if it is missing.
+func newBotGame(full *GameFull, color string, client *Client) (*BotGame, error) {
+	game := &BotGame{ID: full.ID, Full: full, Variant: full.Variant.Key}
+	if full.InitialFen != "startpos" {
+		game.InitialFen = full.InitialFen
+	}
+	if _, err := variantInitialPosition(game.Variant, game.InitialFen); err != nil {
+		return nil, err
+	}
+
+	switch color {
+	case "white":
+		game.Color = White
+	case "black":
+		game.Color = Black
+	default:
+		account, err := client.GetAccount()
+		if err != nil {
+			return nil, err
+		}
+		color, ok := full.ColorOf(account.ID)
+		if !ok {
+			return nil, fmt.Errorf("%s does not play in game %s", account.ID, full.ID)
+		}
+		game.Color = color
+	}
+	return game, nil
+}
+
+func (g *botGameRunner) update(state *GameState) error {
+	game := g.game
+	game.State = state
+	game.Moves = state.MoveList()
+
+	pos, _ := variantInitialPosition(game.Variant, game.InitialFen)
+	for _, uci := range game.Moves {
+		m, err := pos.ParseUCI(uci)
+		if err != nil {
+			return fmt.Errorf("illegal move %s from the server: %v", uci, err)
+		}
+		pos = pos.Play(m)
+	}
+	game.Position = pos
+
+	if g.claim != nil {
+		g.claim.Stop()
+		g.claim = nil
+	}
+	if state.Finished() || pos.Turn != game.Color || len(game.Moves) == g.moved {
+		return nil
+	}
+	return g.move()
+}
+
+func (g *botGameRunner) move() error {
+	game := g.game
+	options := g.runner.Options
+	if game.Full.Clock != nil {
+		game.Budget = options.MoveBudget(game.State.Time(game.Color), game.State.Increment(game.Color))
+	} else {
+		game.Budget = options.MoveBudget(0, 0)
+	}
+
+	choice, err := g.choose()
+	if errors.Is(err, ErrUnsupportedVariant) {
+		g.runner.logf("game %s: resigning: %v", game.ID, err)
+		choice.Resign = true
+	} else if err != nil {
+		legal := game.Position.LegalMoves()
+		if len(legal) == 0 {
+			return nil
+		}
+		choice = BotMove{Move: game.Position.UCI(legal[0])}
+		g.runner.logf("game %s: playing %s instead: %v", game.ID, choice.Move, err)
+	}
+	if choice.Resign {
+		if err := g.api.Resign(game.ID); err != nil {
+			g.runner.logf("game %s: resigning: %v", game.ID, err)
+		}
+		return nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = g.api.Move(game.ID, choice.Move, choice.OfferDraw)
+		if err == nil {
+			g.moved = len(game.Moves)
+			return nil
+		}
+		if attempt >= options.MoveRetries || stopped(g.runner.kill) {
+			break
+		}
+		g.runner.logf("game %s: move %s: %v", game.ID, choice.Move, err)
+		time.Sleep(options.Stream.MinBackoff)
+	}
+	// The game goes on: a reconnect or the next state may still let the
+	// bot move.
+	g.runner.logf("game %s: giving up on move %s: %v", game.ID, choice.Move, err)
+	return nil
+}
+
+// choose asks the chooser for a move, asking again after a failure while
+// the retries and the clock allow it.
+func (g *botGameRunner) choose() (BotMove, error) {
+	game := g.game
+	options := g.runner.Options
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		choice, err := g.runner.Chooser.ChooseMove(game)
+		if err == nil && !choice.Resign && choice.Move == "" {
+			err = errors.New("no move chosen")
+		}
+		if err == nil || errors.Is(err, ErrUnsupportedVariant) {
+			return choice, err
+		}
+		if attempt >= options.MoveRetries || stopped(g.runner.kill) {
+			return choice, err
+		}
+		if game.Full.Clock != nil && time.Since(start)+game.Budget > game.State.Time(game.Color)/2 {
+			return choice, err
+		}
+		g.runner.logf("game %s: choosing a move: %v", game.ID, err)
+	}
+}
+
+// opponentGone claims the win once the server allows it.
+func (g *botGameRunner) opponentGone(gone *OpponentGone) {
+	if g.claim != nil {
+		g.claim.Stop()
+		g.claim = nil
+	}
+	if gone == nil || !gone.Gone || g.game == nil {
+		return
+	}
+	id := g.game.ID
+	g.claim = time.AfterFunc(time.Duration(gone.ClaimWinInSeconds)*time.Second, func() {
+		if err := g.api.ClaimVictory(id); err != nil {
+			g.runner.logf("game %s: claiming victory: %v", id, err)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLichess serves the event stream and the bot game stream of a single
+// game in which the bot plays white and the opponent answers from a script.
+type fakeLichess struct {
+	t       *testing.T
+	replies []string
+
+	mu       sync.Mutex
+	requests []string
+	moves    []string
+	events   chan string
+	states   chan string
+	finished chan struct{}
+}
+
+func newFakeLichess(t *testing.T, replies ...string) *fakeLichess {
+	return &fakeLichess{
+		t:        t,
+		replies:  replies,
+		events:   make(chan string, 10),
+		states:   make(chan string, 10),
+		finished: make(chan struct{}),
+	}
+}
+
+func (f *fakeLichess) state(status string) string {
+	return fmt.Sprintf(`{"type":"gameState","moves":"%s","wtime":60000,"btime":60000,"winc":2000,"binc":2000,"status":"%s"}`, strings.Join(f.moves, " "), status)
+}
+
+func (f *fakeLichess) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.mu.Unlock()
+
+	stream := func(lines chan string) {
+		flusher := w.(http.Flusher)
+		fmt.Fprintln(w)
+		flusher.Flush()
+		for {
+			select {
+			case line := <-lines:
+				fmt.Fprintln(w, line)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	switch {
+	case r.URL.Path == "/api/stream/event":
+		stream(f.events)
+	case r.URL.Path == "/api/challenge/game1/accept":
+		f.events <- `{"type":"gameStart","game":{"gameId":"game1","fullId":"game1abcd","color":"white","compat":{"bot":true}}}`
+		fmt.Fprint(w, `{"ok":true}`)
+	case strings.HasPrefix(r.URL.Path, "/api/challenge/"):
+		fmt.Fprint(w, `{"ok":true}`)
+	case r.URL.Path == "/api/bot/game/stream/game1":
+		fmt.Fprintln(w, `{"type":"gameFull","id":"game1","variant":{"key":"standard"},"clock":{"initial":60000,"increment":2000},"white":{"id":"bot","name":"Bot"},"black":{"id":"human","name":"Human"},"initialFen":"startpos","state":`+f.state("started")+`}`)
+		stream(f.states)
+	case strings.HasPrefix(r.URL.Path, "/api/bot/game/game1/move/"):
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.moves = append(f.moves, strings.TrimPrefix(r.URL.Path, "/api/bot/game/game1/move/"))
+		f.states <- f.state("started")
+		if len(f.replies) == 0 {
+			f.states <- f.state("resign")
+			close(f.finished)
+		} else {
+			f.moves = append(f.moves, f.replies[0])
+			f.replies = f.replies[1:]
+			f.states <- f.state("started")
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeLichess) requested(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matches []string
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, prefix) {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
+
+func TestBotRunner(t *testing.T) {
+	fake := newFakeLichess(t, "e7e5", "b8c6")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var budgets []time.Duration
+	chooser := MoveChooserFunc(func(game *BotGame) (BotMove, error) {
+		mu.Lock()
+		budgets = append(budgets, game.Budget)
+		mu.Unlock()
+		if game.Color != White || game.Position.Turn != White {
+			return BotMove{}, fmt.Errorf("asked to move for the wrong side")
+		}
+		// Play the first legal move in UCI order of a few candidates.
+		for _, uci := range []string{"e2e4", "g1f3", "f1c4"} {
+			if m, err := game.Position.ParseUCI(uci); err == nil && game.Position.isLegal(m) {
+				return BotMove{Move: uci}, nil
+			}
+		}
+		return BotMove{}, fmt.Errorf("no candidate move")
+	})
+
+	bot := NewBotRunner(&Client{Token: "bot-token", HttpClient: server.Client(), BaseURL: server.URL}, chooser)
+	bot.Options.MaxGames = 1
+	bot.Options.MoveOverhead = 100 * time.Millisecond
+	done := make(chan error)
+	go func() { done <- bot.Run() }()
+
+	fake.events <- `{"type":"challenge","challenge":{"id":"game1","status":"created","challenger":{"id":"human","name":"Human"},"direction":"in"}}`
+	fake.events <- `{"type":"challenge","challenge":{"id":"game2","status":"created","challenger":{"id":"other","name":"Other"},"direction":"in"}}`
+	fake.events <- `{"type":"challenge","challenge":{"id":"mine","status":"created","challenger":{"id":"bot","name":"Bot"},"direction":"out"}}`
+
+	select {
+	case <-fake.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The game did not finish, requests: %v", fake.requested(""))
+	}
+	bot.Shutdown()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not return after shutdown")
+	}
+
+	if moves := strings.Join(fake.moves, " "); moves != "e2e4 e7e5 g1f3 b8c6 f1c4" {
+		t.Errorf("Unexpected moves %q", moves)
+	}
+	if accepted := fake.requested("POST /api/challenge/game1/accept"); len(accepted) != 1 {
+		t.Errorf("Expected the first challenge to be accepted")
+	}
+	if declined := fake.requested("POST /api/challenge/game2/decline"); len(declined) != 1 {
+		t.Errorf("Expected the second challenge to be declined over the game limit")
+	}
+	if mine := fake.requested("POST /api/challenge/mine"); len(mine) != 0 {
+		t.Errorf("Expected outgoing challenges to be left alone, got %v", mine)
+	}
+	// 60s over 30 moves plus three quarters of the 2s increment, less the
+	// overhead.
+	if len(budgets) != 3 || budgets[0] != 3400*time.Millisecond {
+		t.Errorf("Unexpected move budgets %v", budgets)
+	}
+	if bot.Playing() != 0 {
+		t.Errorf("Expected no game in progress")
+	}
+}
+
+func TestBotRunnerChooserFailure(t *testing.T) {
+	fake := newFakeLichess(t, "e7e5", "b8c6")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var calls int32
+	chooser := MoveChooserFunc(func(game *BotGame) (BotMove, error) {
+		atomic.AddInt32(&calls, 1)
+		return BotMove{}, ErrEngineTimeout
+	})
+	bot := NewBotRunner(&Client{Token: "bot-token", HttpClient: server.Client(), BaseURL: server.URL}, chooser)
+	bot.Options.MoveRetries = 1
+	done := make(chan error)
+	go func() { done <- bot.Run() }()
+	fake.events <- `{"type":"challenge","challenge":{"id":"game1","status":"created","challenger":{"id":"human","name":"Human"},"direction":"in"}}`
+
+	select {
+	case <-fake.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The game did not finish, requests: %v", fake.requested(""))
+	}
+	bot.Shutdown()
+	<-done
+
+	if resigned := fake.requested("POST /api/bot/game/game1/resign"); len(resigned) != 0 {
+		t.Errorf("Expected the bot not to resign after engine failures")
+	}
+	if len(fake.moves) != 5 {
+		t.Errorf("Expected legal moves to be played instead, got %v", fake.moves)
+	}
+	if n := atomic.LoadInt32(&calls); n != 6 {
+		t.Errorf("Expected each move to be asked for twice, got %d calls", n)
+	}
+}
+
+func TestEngineMoveChooserVariant(t *testing.T) {
+	engine := NewEngine(buildFakeEngine(t))
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pool := NewEnginePoolOf(engine)
+	defer pool.Close()
+	chooser := &EngineMoveChooser{Pool: pool}
+
+	chess960 := &BotGame{
+		ID:         "game960",
+		Variant:    VariantChess960,
+		InitialFen: "bqnrkrnb/pppppppp/8/8/8/8/PPPPPPPP/BQNRKRNB w KQkq - 0 1",
+		Budget:     10 * time.Millisecond,
+	}
+	if move, err := chooser.ChooseMove(chess960); err != nil || move.Move == "" {
+		t.Fatalf("Unexpected chess960 move %v, error %v", move, err)
+	}
+	if v := engine.values["UCI_Chess960"]; v != "true" {
+		t.Errorf("Expected UCI_Chess960 to be set for a chess960 game, got %q", v)
+	}
+
+	standard := &BotGame{ID: "game1", Variant: VariantStandard, Budget: 10 * time.Millisecond}
+	if _, err := chooser.ChooseMove(standard); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v := engine.values["UCI_Chess960"]; v != "false" {
+		t.Errorf("Expected UCI_Chess960 to be reset for a standard game, got %q", v)
+	}
+
+	crazyhouse := &BotGame{ID: "zh", Variant: VariantCrazyhouse, Budget: 10 * time.Millisecond}
+	if _, err := chooser.ChooseMove(crazyhouse); err != ErrUnsupportedVariant {
+		t.Errorf("Expected ErrUnsupportedVariant without UCI_Variant, got %v", err)
+	}
+}
+
+func TestMoveBudget(t *testing.T) {
+	opts := NewBotOptions()
+	if b := opts.MoveBudget(0, 0); b != opts.MaxMoveTime {
+		t.Errorf("Expected the maximum move time without a clock, got %v", b)
+	}
+	if b := opts.MoveBudget(time.Hour, 0); b != opts.MaxMoveTime {
+		t.Errorf("Expected the budget to be capped, got %v", b)
+	}
+	if b := opts.MoveBudget(time.Second, 10*time.Second); b != 200*time.Millisecond {
+		t.Errorf("Expected at most half the remaining time, got %v", b)
+	}
+	if b := opts.MoveBudget(100*time.Millisecond, 0); b != opts.MinMoveTime {
+		t.Errorf("Expected the minimum move time, got %v", b)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBotAPI(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/api/bot/online":
+			fmt.Fprintln(w, `{"id":"maia1","username":"maia1","title":"BOT","perfs":{"blitz":{"games":120,"rating":1510,"rd":45,"prog":12}}}`)
+			fmt.Fprintln(w, `{"id":"stockfish","username":"Stockfish","title":"BOT","perfs":{"blitz":{"games":4000,"rating":2900,"rd":50,"prog":0}}}`)
+		case "/api/bot/game/stream/xyz":
+			fmt.Fprintln(w, gameFullEvent)
+			fmt.Fprintln(w, `{"type":"gameState","moves":"e2e4 c7c5 f2f4 d7d6","wtime":7598040,"btime":8390000,"winc":10000,"binc":10000,"status":"mate","winner":"white"}`)
+		default:
+			fmt.Fprint(w, `{"ok":true}`)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Token: "secret", HttpClient: server.Client(), BaseURL: server.URL}
+	if err := c.UpgradeToBot(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	bot := c.Bot()
+	if err := bot.Move("xyz", "g1f3", true); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	bot.Chat("xyz", ChatRoomSpectator, "hello")
+	bot.Resign("xyz")
+
+	var types []string
+	err := bot.StreamGame("xyz", NewStreamOptions(), func(e *GameStreamEvent) error {
+		types = append(types, e.Type)
+		return nil
+	})
+	if err != nil || strings.Join(types, ",") != "gameFull,gameState" {
+		t.Errorf("Unexpected game stream %v (%v)", types, err)
+	}
+
+	bots, err := c.GetOnlineBots(2)
+	if err != nil || len(bots) != 2 || bots[1].Username != "Stockfish" || bots[0].Perfs.Blitz.Rating != 1510 {
+		t.Errorf("Unexpected bots %+v (%v)", bots, err)
+	}
+
+	expected := []string{
+		"POST /api/bot/account/upgrade",
+		"POST /api/bot/game/xyz/move/g1f3?offeringDraw=true",
+		"POST /api/bot/game/xyz/chat",
+		"POST /api/bot/game/xyz/resign",
+		"GET /api/bot/game/stream/xyz",
+		"GET /api/bot/online?nb=2",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons for declining a challenge, as understood by Lichess.
+const (
+	DeclineGeneric     = "generic"
+	DeclineLater       = "later"
+	DeclineTooFast     = "tooFast"
+	DeclineTooSlow     = "tooSlow"
+	DeclineTimeControl = "timeControl"
+	DeclineRated       = "rated"
+	DeclineCasual      = "casual"
+	DeclineStandard    = "standard"
+	DeclineVariant     = "variant"
+	DeclineNoBot       = "noBot"
+	DeclineOnlyBot     = "onlyBot"
+)
+
+func challengePath(id string, action string) string {
+	return "/api/challenge/" + url.PathEscape(id) + "/" + action
+}
+
+func (c *Client) AcceptChallenge(id string) error {
+	return c.post(challengePath(id, "accept"), nil)
+}
+
+// DeclineChallenge declines a challenge with one of the Decline reasons, or
+// the generic one if reason is empty.
+func (c *Client) DeclineChallenge(id, reason string) error {
+	values := url.Values{}
+	if reason != "" {
+		values.Set("reason", reason)
+	}
+	return c.post(challengePath(id, "decline"), values)
+}
+
+// Rules that can be added to a challenge.
+const (
+	RuleNoAbort     = "noAbort"
+	RuleNoRematch   = "noRematch"
+	RuleNoGiveTime  = "noGiveTime"
+	RuleNoClaimWin  = "noClaimWin"
+	RuleNoEarlyDraw = "noEarlyDraw"
+)
+
+// ChallengeParams are the settings of a challenge sent to a user. A
+// challenge has a clock, days per turn, or neither for unlimited time.
+type ChallengeParams struct {
+	Rated     bool
+	Limit     time.Duration
+	Increment time.Duration
+	Days      int
+	Color     string
+	Variant   string
+	Fen       string
+	Rules     []string
+	// Message is sent to the opponent when the game starts. It may use
+	// the {player}, {opponent} and {game} placeholders.
+	Message string
+}
+
+func (p ChallengeParams) Values() url.Values {
+	values := url.Values{}
+	values.Set("rated", strconv.FormatBool(p.Rated))
+	if p.Limit > 0 || p.Increment > 0 {
+		values.Set("clock.limit", strconv.Itoa(int(p.Limit/time.Second)))
+		values.Set("clock.increment", strconv.Itoa(int(p.Increment/time.Second)))
+	} else if p.Days > 0 {
+		values.Set("days", strconv.Itoa(p.Days))
+	}
+	if p.Color != "" {
+		values.Set("color", p.Color)
+	}
+	if p.Variant != "" {
+		values.Set("variant", p.Variant)
+	}
+	if p.Fen != "" {
+		values.Set("fen", p.Fen)
+	}
+	if len(p.Rules) > 0 {
+		values.Set("rules", strings.Join(p.Rules, ","))
+	}
+	if p.Message != "" {
+		values.Set("message", p.Message)
+	}
+	return values
+}
+
+// Perf returns the rating category of games with these settings.
+func (p ChallengeParams) Perf() string {
+	switch {
+	case p.Variant != "" && p.Variant != VariantStandard && p.Variant != VariantFromPosition:
+		return p.Variant
+	case p.Limit > 0 || p.Increment > 0:
+		return speedFromClock(int(p.Limit/time.Second), int(p.Increment/time.Second))
+	}
+	return "correspondence"
+}
+
+func (c *Client) postJSON(endPoint string, values url.Values, dest interface{}) error {
+	req, err := c.newPostRequest(endPoint, values)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+type ChallengeList struct {
+	In  []Challenge `json:"in"`
+	Out []Challenge `json:"out"`
+}
+
+// ListChallenges returns the challenges received and sent by the account
+// that are still waiting for an answer.
+func (c *Client) ListChallenges() (*ChallengeList, error) {
+	req, err := c.NewRequest(c.baseURL()+"/api/challenge", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	var list ChallengeList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateChallenge challenges a user. The challenge waits for an answer on
+// the event stream of the user.
+func (c *Client) CreateChallenge(username string, params ChallengeParams) (*Challenge, error) {
+	if username == "" {
+		return nil, errors.New("must provide a valid username")
+	}
+	// Lichess answers with the challenge, alone or wrapped in a field.
+	var data json.RawMessage
+	if err := c.postJSON("/api/challenge/"+url.PathEscape(username), params.Values(), &data); err != nil {
+		return nil, err
+	}
+	var wrapped struct {
+		Challenge *Challenge `json:"challenge"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Challenge != nil {
+		return wrapped.Challenge, nil
+	}
+	var challenge Challenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CancelChallenge cancels a challenge sent by the account. With the token
+// of the opponent, a game that started from the challenge is aborted too.
+func (c *Client) CancelChallenge(id, opponentToken string) error {
+	endPoint := challengePath(id, "cancel")
+	if opponentToken != "" {
+		endPoint += "?opponentToken=" + url.QueryEscape(opponentToken)
+	}
+	return c.post(endPoint, nil)
+}
+
+// AIGame is the game started by a challenge to the Lichess AI.
+type AIGame struct {
+	ID        string          `json:"id"`
+	FullID    string          `json:"fullId"`
+	Variant   EventVariant    `json:"variant"`
+	Speed     string          `json:"speed"`
+	Perf      string          `json:"perf"`
+	Rated     bool            `json:"rated"`
+	Fen       string          `json:"fen"`
+	Turns     int             `json:"turns"`
+	Source    string          `json:"source"`
+	Status    GameEventStatus `json:"status"`
+	CreatedAt int64           `json:"createdAt"`
+	Player    string          `json:"player"`
+}
+
+// ChallengeAI starts a game against the Lichess AI at a level from 1 to 8.
+// The rated setting and rules of the params do not apply.
+func (c *Client) ChallengeAI(level int, params ChallengeParams) (*AIGame, error) {
+	if level < 1 || level > 8 {
+		return nil, fmt.Errorf("AI level %d is not between 1 and 8", level)
+	}
+	values := params.Values()
+	values.Del("rated")
+	values.Del("rules")
+	values.Del("message")
+	values.Set("level", strconv.Itoa(level))
+	var game AIGame
+	if err := c.postJSON("/api/challenge/ai", values, &game); err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+type OpenChallengeParams struct {
+	ChallengeParams
+	Name string
+	// Users restricts the challenge to two players. Anyone can join it
+	// otherwise.
+	Users []string
+}
+
+// OpenChallenge can be joined by two players through the URL of each
+// color.
+type OpenChallenge struct {
+	Challenge
+	URLWhite string `json:"urlWhite"`
+	URLBlack string `json:"urlBlack"`
+	Open     struct {
+		UserIDs []string `json:"userIds,omitempty"`
+	} `json:"open"`
+}
+
+func (c *Client) CreateOpenChallenge(params OpenChallengeParams) (*OpenChallenge, error) {
+	values := params.Values()
+	values.Del("color")
+	values.Del("message")
+	if params.Name != "" {
+		values.Set("name", params.Name)
+	}
+	if len(params.Users) > 0 {
+		values.Set("users", strings.Join(params.Users, ","))
+	}
+	var challenge OpenChallenge
+	if err := c.postJSON("/api/challenge/open", values, &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// ChallengePolicy decides which challenges a bot accepts. Empty lists allow
+// everything, and zero limits are not checked. Times are in seconds.
+type ChallengePolicy struct {
+	Variants     []string `json:"variants"`
+	Speeds       []string `json:"speeds"`
+	MinTime      int      `json:"minTime"`
+	MaxTime      int      `json:"maxTime"`
+	MinIncrement int      `json:"minIncrement"`
+	MaxIncrement int      `json:"maxIncrement"`
+	MinDays      int      `json:"minDays"`
+	MaxDays      int      `json:"maxDays"`
+	Unlimited    bool     `json:"unlimited"`
+	Rated        bool     `json:"rated"`
+	Casual       bool     `json:"casual"`
+	MinRating    int      `json:"minRating"`
+	MaxRating    int      `json:"maxRating"`
+	Bots         bool     `json:"bots"`
+	Humans       bool     `json:"humans"`
+	MaxGames     int      `json:"maxGames"`
+	Allowlist    []string `json:"allowlist"`
+	Blocklist    []string `json:"blocklist"`
+}
+
+// NewChallengePolicy accepts standard games at any speed with a clock or
+// days per turn, rated or casual, from anyone.
+func NewChallengePolicy() ChallengePolicy {
+	return ChallengePolicy{
+		Variants: []string{VariantStandard},
+		Rated:    true,
+		Casual:   true,
+		Bots:     true,
+		Humans:   true,
+	}
+}
+
+// LoadChallengePolicy reads a policy from a JSON file. Fields missing from
+// the file keep the defaults of NewChallengePolicy.
+func LoadChallengePolicy(path string) (*ChallengePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := NewChallengePolicy()
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func outOfRange(value, min, max int) (below, above bool) {
+	return min > 0 && value < min, max > 0 && value > max
+}
+
+// DeclineReason returns the reason to decline the challenge with, or an
+// empty string to accept it. Playing is the number of games in progress.
+func (p *ChallengePolicy) DeclineReason(ch *Challenge, playing int) string {
+	var challenger ChallengeUser
+	if ch.Challenger != nil {
+		challenger = *ch.Challenger
+	}
+	if containsFold(p.Blocklist, challenger.ID) || (len(p.Allowlist) > 0 && !containsFold(p.Allowlist, challenger.ID)) {
+		return DeclineGeneric
+	}
+
+	isBot := challenger.Title == "BOT"
+	if isBot && !p.Bots {
+		return DeclineNoBot
+	}
+	if !isBot && !p.Humans {
+		return DeclineOnlyBot
+	}
+
+	variant := ch.Variant.Key
+	if variant == "" {
+		variant = VariantStandard
+	}
+	if len(p.Variants) > 0 && !containsFold(p.Variants, variant) {
+		if len(p.Variants) == 1 && p.Variants[0] == VariantStandard {
+			return DeclineStandard
+		}
+		return DeclineVariant
+	}
+
+	tc := ch.TimeControl
+	switch tc.Type {
+	case "unlimited":
+		if !p.Unlimited {
+			return DeclineTimeControl
+		}
+	case "correspondence":
+		if tooFast, tooSlow := outOfRange(tc.DaysPerTurn, p.MinDays, p.MaxDays); tooFast {
+			return DeclineTooFast
+		} else if tooSlow {
+			return DeclineTooSlow
+		}
+	default:
+		if tooFast, tooSlow := outOfRange(tc.Limit, p.MinTime, p.MaxTime); tooFast {
+			return DeclineTooFast
+		} else if tooSlow {
+			return DeclineTooSlow
+		}
+		if tooFast, tooSlow := outOfRange(tc.Increment, p.MinIncrement, p.MaxIncrement); tooFast {
+			return DeclineTooFast
+		} else if tooSlow {
+			return DeclineTooSlow
+		}
+	}
+	if len(p.Speeds) > 0 && tc.Type != "unlimited" && !containsFold(p.Speeds, ch.Speed) {
+		return DeclineTimeControl
+	}
+
+	if ch.Rated && !p.Rated {
+		return DeclineCasual
+	}
+	if !ch.Rated && !p.Casual {
+		return DeclineRated
+	}
+
+	if low, high := outOfRange(challenger.Rating, p.MinRating, p.MaxRating); low || high {
+		return DeclineGeneric
+	}
+	if p.MaxGames > 0 && playing >= p.MaxGames {
+		return DeclineLater
+	}
+	return ""
+}
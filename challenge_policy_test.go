@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testChallenge(modify func(ch *Challenge)) *Challenge {
+	ch := &Challenge{
+		ID:          "abc",
+		Challenger:  &ChallengeUser{ID: "alice", Name: "Alice", Rating: 1800},
+		Variant:     EventVariant{Key: VariantStandard},
+		Rated:       true,
+		Speed:       "blitz",
+		TimeControl: ChallengeTimeControl{Type: "clock", Limit: 180, Increment: 2},
+	}
+	if modify != nil {
+		modify(ch)
+	}
+	return ch
+}
+
+func TestChallengePolicy(t *testing.T) {
+	policy := NewChallengePolicy()
+	policy.Speeds = []string{"blitz", "rapid", "correspondence"}
+	policy.MinTime, policy.MaxTime = 60, 1800
+	policy.MaxIncrement = 30
+	policy.MaxDays = 7
+	policy.MinRating, policy.MaxRating = 1200, 2500
+	policy.Bots = false
+	policy.MaxGames = 2
+	policy.Blocklist = []string{"Troll"}
+
+	tests := []struct {
+		name    string
+		modify  func(ch *Challenge)
+		playing int
+		reason  string
+	}{
+		{"accepted", nil, 0, ""},
+		{"blocked", func(ch *Challenge) { ch.Challenger.ID = "troll" }, 0, DeclineGeneric},
+		{"bot", func(ch *Challenge) { ch.Challenger.Title = "BOT" }, 0, DeclineNoBot},
+		{"variant", func(ch *Challenge) { ch.Variant.Key = VariantAtomic }, 0, DeclineStandard},
+		{"too fast", func(ch *Challenge) { ch.TimeControl.Limit = 30 }, 0, DeclineTooFast},
+		{"too slow", func(ch *Challenge) { ch.TimeControl.Limit = 3600 }, 0, DeclineTooSlow},
+		{"increment", func(ch *Challenge) { ch.TimeControl.Increment = 60 }, 0, DeclineTooSlow},
+		{"speed", func(ch *Challenge) { ch.Speed = "bullet"; ch.TimeControl.Limit = 60; ch.TimeControl.Increment = 1 }, 0, DeclineTimeControl},
+		{"correspondence", func(ch *Challenge) {
+			ch.Speed = "correspondence"
+			ch.TimeControl = ChallengeTimeControl{Type: "correspondence", DaysPerTurn: 3}
+		}, 0, ""},
+		{"long correspondence", func(ch *Challenge) {
+			ch.Speed = "correspondence"
+			ch.TimeControl = ChallengeTimeControl{Type: "correspondence", DaysPerTurn: 14}
+		}, 0, DeclineTooSlow},
+		{"unlimited", func(ch *Challenge) {
+			ch.Speed = "correspondence"
+			ch.TimeControl = ChallengeTimeControl{Type: "unlimited"}
+		}, 0, DeclineTimeControl},
+		{"weak", func(ch *Challenge) { ch.Challenger.Rating = 900 }, 0, DeclineGeneric},
+		{"busy", nil, 2, DeclineLater},
+	}
+	for _, test := range tests {
+		if reason := policy.DeclineReason(testChallenge(test.modify), test.playing); reason != test.reason {
+			t.Errorf("%s: expected %q, got %q", test.name, test.reason, reason)
+		}
+	}
+
+	policy = NewChallengePolicy()
+	policy.Variants = []string{VariantStandard, VariantChess960}
+	policy.Rated = false
+	policy.Humans = false
+	policy.Allowlist = []string{"friendbot", "alice"}
+	if reason := policy.DeclineReason(testChallenge(nil), 0); reason != DeclineOnlyBot {
+		t.Errorf("Expected humans to be declined, got %q", reason)
+	}
+	bot := func(ch *Challenge) { ch.Challenger.Title = "BOT" }
+	if reason := policy.DeclineReason(testChallenge(bot), 0); reason != DeclineCasual {
+		t.Errorf("Expected rated games to be declined, got %q", reason)
+	}
+	if reason := policy.DeclineReason(testChallenge(func(ch *Challenge) { bot(ch); ch.Rated = false; ch.Variant.Key = VariantHorde }), 0); reason != DeclineVariant {
+		t.Errorf("Expected the variant to be declined, got %q", reason)
+	}
+	if reason := policy.DeclineReason(testChallenge(func(ch *Challenge) { bot(ch); ch.Challenger.ID = "stranger" }), 0); reason != DeclineGeneric {
+		t.Errorf("Expected users outside the allowlist to be declined, got %q", reason)
+	}
+}
+
+func TestLoadChallengePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(path, []byte(`{"speeds":["rapid"],"casual":false,"maxRating":2000}`), 0644)
+	policy, err := LoadChallengePolicy(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.Casual || !policy.Rated || policy.MaxRating != 2000 || len(policy.Variants) != 1 || policy.Speeds[0] != "rapid" {
+		t.Errorf("Unexpected policy %+v", policy)
+	}
+}
+
+func TestBotRunnerPolicy(t *testing.T) {
+	var mu sync.Mutex
+	var declines []string
+	events := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/stream/event":
+			w.Write([]byte(`{"type":"challenge","challenge":{"id":"c1","challenger":{"id":"robot","title":"BOT"},"variant":{"key":"standard"},"timeControl":{"type":"clock","limit":60}}}` + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case strings.HasSuffix(r.URL.Path, "/decline"):
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			declines = append(declines, r.URL.Path+" "+string(body))
+			mu.Unlock()
+			events <- r.URL.Path
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	bot := NewBotRunner(&Client{HttpClient: server.Client(), BaseURL: server.URL}, nil)
+	policy := NewChallengePolicy()
+	policy.Bots = false
+	bot.Policy = &policy
+	done := make(chan error)
+	go func() { done <- bot.Run() }()
+	<-events
+	bot.Shutdown()
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(declines) != 1 || declines[0] != "/api/challenge/c1/decline reason=noBot" {
+		t.Errorf("Unexpected declines %v", declines)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChallengesAPI(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		switch r.URL.Path {
+		case "/api/challenge":
+			fmt.Fprint(w, `{"in":[`+strings.TrimSuffix(strings.SplitN(challengeEvent, `"challenge":`, 2)[1], `,"compat":{"bot":true,"board":true}}`)+`],"out":[{"id":"out1","status":"created","direction":"out","rules":["noAbort"]}]}`)
+		case "/api/challenge/bobby":
+			fmt.Fprint(w, `{"id":"H9fIRZUk","url":"https://lichess.org/H9fIRZUk","status":"created","destUser":{"id":"bobby","name":"Bobby"},"rules":["noRematch","noGiveTime"]}`)
+		case "/api/challenge/ai":
+			fmt.Fprint(w, `{"id":"q7ZvsdUF","fullId":"q7ZvsdUFabcd","variant":{"key":"standard","name":"Standard"},"speed":"blitz","perf":"blitz","rated":false,"fen":"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1","turns":0,"source":"ai","status":{"id":20,"name":"started"},"createdAt":1525789431889,"player":"white"}`)
+		case "/api/challenge/open":
+			fmt.Fprint(w, `{"id":"VU0nyvsW","url":"https://lichess.org/VU0nyvsW","status":"created","rated":false,"speed":"rapid","timeControl":{"type":"clock","limit":600,"increment":5,"show":"10+5"},"urlWhite":"https://lichess.org/VU0nyvsW?color=white","urlBlack":"https://lichess.org/VU0nyvsW?color=black","open":{"userIds":["alice","bob"]}}`)
+		case "/api/challenge/gone/accept":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"Challenge not found"}`)
+		default:
+			fmt.Fprint(w, `{"ok":true}`)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Token: "secret", HttpClient: server.Client(), BaseURL: server.URL}
+
+	list, err := c.ListChallenges()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list.In) != 1 || list.In[0].ID != "7pGLxJ4F" || list.In[0].Challenger.Name != "Bobby" || len(list.Out) != 1 || list.Out[0].Rules[0] != RuleNoAbort {
+		t.Errorf("Unexpected challenges %+v", list)
+	}
+
+	challenge, err := c.CreateChallenge("bobby", ChallengeParams{
+		Rated:   true,
+		Limit:   5 * time.Minute,
+		Color:   "white",
+		Variant: VariantFromPosition,
+		Fen:     "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1",
+		Rules:   []string{RuleNoRematch, RuleNoGiveTime},
+		Message: "Your game with {opponent} is ready: {game}.",
+	})
+	if err != nil || challenge.ID != "H9fIRZUk" || challenge.DestUser.ID != "bobby" || len(challenge.Rules) != 2 {
+		t.Errorf("Unexpected challenge %+v (%v)", challenge, err)
+	}
+
+	game, err := c.ChallengeAI(3, ChallengeParams{Rated: true, Limit: 3 * time.Minute, Increment: 2 * time.Second, Color: "white"})
+	if err != nil || game.FullID != "q7ZvsdUFabcd" || game.Player != "white" || game.Status.Name != "started" {
+		t.Errorf("Unexpected AI game %+v (%v)", game, err)
+	}
+	if _, err := c.ChallengeAI(9, ChallengeParams{}); err == nil {
+		t.Errorf("Expected an error for an AI level above 8")
+	}
+
+	open, err := c.CreateOpenChallenge(OpenChallengeParams{
+		ChallengeParams: ChallengeParams{Limit: 10 * time.Minute, Increment: 5 * time.Second},
+		Name:            "Club final",
+		Users:           []string{"alice", "bob"},
+	})
+	if err != nil || open.ID != "VU0nyvsW" || open.URLWhite != "https://lichess.org/VU0nyvsW?color=white" || open.URLBlack == "" || len(open.Open.UserIDs) != 2 || open.TimeControl.Show != "10+5" {
+		t.Errorf("Unexpected open challenge %+v (%v)", open, err)
+	}
+
+	for _, err := range []error{
+		c.AcceptChallenge("H9fIRZUk"),
+		c.DeclineChallenge("H9fIRZUk", DeclineTooFast),
+		c.CancelChallenge("H9fIRZUk", ""),
+		c.CancelChallenge("H9fIRZUk", "opponent-token"),
+	} {
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	err = c.AcceptChallenge("gone")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Challenge not found" {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+
+	expected := []string{
+		"GET /api/challenge ",
+		"POST /api/challenge/bobby clock.increment=0&clock.limit=300&color=white&fen=4k3%2F8%2F8%2F8%2F8%2F8%2F4P3%2F4K3+w+-+-+0+1&message=Your+game+with+%7Bopponent%7D+is+ready%3A+%7Bgame%7D.&rated=true&rules=noRematch%2CnoGiveTime&variant=fromPosition",
+		"POST /api/challenge/ai clock.increment=2&clock.limit=180&color=white&level=3",
+		"POST /api/challenge/open clock.increment=5&clock.limit=600&name=Club+final&rated=false&users=alice%2Cbob",
+		"POST /api/challenge/H9fIRZUk/accept ",
+		"POST /api/challenge/H9fIRZUk/decline reason=tooFast",
+		"POST /api/challenge/H9fIRZUk/cancel ",
+		"POST /api/challenge/H9fIRZUk/cancel?opponentToken=opponent-token ",
+		"POST /api/challenge/gone/accept ",
+	}
+	for i := range expected {
+		if i >= len(requests) || requests[i] != expected[i] {
+			got := ""
+			if i < len(requests) {
+				got = requests[i]
+			}
+			t.Errorf("Request %d: expected\n%s\ngot\n%s", i, expected[i], got)
+		}
+	}
+}
@@ -18,6 +18,14 @@ const (
 type Client struct {
 	Token      string
 	HttpClient *http.Client
+	BaseURL    string
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return LichessBase
 }
 
 type RequestParams struct {
@@ -72,7 +80,7 @@ func (c *Client) NewRequest(url string, params *RequestParams) (*http.Request, e
 }
 
 func (c *Client) DoRequest(endPoint string, dest interface{}, params *RequestParams) (*http.Response, error) {
-	req, err := c.NewRequest(LichessBase+endPoint, params)
+	req, err := c.NewRequest(c.baseURL()+endPoint, params)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +187,7 @@ func (c *Client) GetTeamMembers(teamId string) ([]Account, error) {
 	params.Authorization = ""
 	team := make([]Account, 0)
 
-	uri := LichessBase + "/api/team/" + teamId + "/users"
+	uri := c.baseURL() + "/api/team/" + teamId + "/users"
 	req, err := c.NewRequest(uri, params)
 	if err != nil {
 		return nil, err
@@ -344,3 +352,84 @@ func (c *Client) GetGame(gameId string, params GameParam) (*Game, error) {
 
 	return &game, nil
 }
+
+func (c *Client) StreamUserGames(username string, params UserGamesParam, fn func(*Game) error) error {
+	if username == "" {
+		return errors.New("must provide a valid username")
+	}
+
+	reqParams := c.DefaultRequestParams()
+	reqParams.Accept = "application/x-ndjson"
+
+	uri := c.baseURL() + "/api/games/user/" + username + "?" + params.Values().Encode()
+	req, err := c.NewRequest(uri, reqParams)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("exporting games of %s: %s", username, resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var game Game
+		if err := decoder.Decode(&game); err != nil {
+			return err
+		}
+		if err := fn(&game); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) ExportUserGames(username string, params UserGamesParam) ([]Game, error) {
+	games := make([]Game, 0)
+	err := c.StreamUserGames(username, params, func(game *Game) error {
+		games = append(games, *game)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return games, nil
+}
+
+// APIError is returned when Lichess answers with an error status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return "lichess: " + e.Status
+	}
+	return "lichess: " + e.Status + ": " + e.Message
+}
+
+// checkResponse turns an error status into an APIError, using the error
+// message of the body if there is one.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		apiErr.Message = payload.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	PhaseOpening    = "opening"
+	PhaseMiddlegame = "middlegame"
+	PhaseEndgame    = "endgame"
+)
+
+const TimeTroubleThreshold = 10 * time.Second
+
+var ErrNoClocks = errors.New("game has no clock times")
+
+// Phases returns the plies at which the middlegame and the endgame start,
+// or 0 when the game never reached them. It approximates the Lichess
+// divider without its mixedness test: the middlegame starts once few pieces
+// remain or a back rank is sparse, so it may start later than on Lichess,
+// and the endgame once at most six major and minor pieces remain.
+func (r *ReplayedGame) Phases() (int, int) {
+	middlegame, endgame := 0, 0
+	for _, ply := range r.Plies {
+		pieces, sparse := phaseMaterial(ply.Position)
+		if middlegame == 0 && (pieces <= 10 || sparse) {
+			middlegame = ply.Number
+		}
+		if pieces <= 6 {
+			if middlegame == 0 {
+				middlegame = ply.Number
+			}
+			endgame = ply.Number
+			break
+		}
+	}
+	return middlegame, endgame
+}
+
+func phaseMaterial(p *Position) (int, bool) {
+	pieces := 0
+	var backRank [2]int
+	for i, pc := range p.Board {
+		if pc.Type == NoPieceType || pc.Type == Pawn || pc.Type == King {
+			continue
+		}
+		pieces++
+		sq := Square(i)
+		if (pc.Color == White && sq.Rank() == 0) || (pc.Color == Black && sq.Rank() == 7) {
+			backRank[pc.Color]++
+		}
+	}
+	return pieces, backRank[White] < 4 || backRank[Black] < 4
+}
+
+func phaseAt(ply, middlegame, endgame int) string {
+	switch {
+	case endgame > 0 && ply >= endgame:
+		return PhaseEndgame
+	case middlegame > 0 && ply >= middlegame:
+		return PhaseMiddlegame
+	}
+	return PhaseOpening
+}
+
+type MoveTime struct {
+	Ply         int
+	Color       Color
+	Spent       time.Duration
+	Remaining   time.Duration
+	Phase       string
+	TimeTrouble bool
+	Judgment    string
+}
+
+type ClockReport struct {
+	Moves               int
+	TotalTime           time.Duration
+	TimeTroubleMoves    int
+	Blunders            int
+	TimeTroubleBlunders int
+	PhaseMoves          map[string]int
+	PhaseTime           map[string]time.Duration
+}
+
+func newClockReport() ClockReport {
+	return ClockReport{PhaseMoves: make(map[string]int), PhaseTime: make(map[string]time.Duration)}
+}
+
+func (r *ClockReport) add(m MoveTime) {
+	r.Moves++
+	r.TotalTime += m.Spent
+	r.PhaseMoves[m.Phase]++
+	r.PhaseTime[m.Phase] += m.Spent
+	if m.TimeTrouble {
+		r.TimeTroubleMoves++
+	}
+	if m.Judgment == JudgmentBlunder {
+		r.Blunders++
+		if m.TimeTrouble {
+			r.TimeTroubleBlunders++
+		}
+	}
+}
+
+func (r *ClockReport) merge(other ClockReport) {
+	r.Moves += other.Moves
+	r.TotalTime += other.TotalTime
+	r.TimeTroubleMoves += other.TimeTroubleMoves
+	r.Blunders += other.Blunders
+	r.TimeTroubleBlunders += other.TimeTroubleBlunders
+	for phase, n := range other.PhaseMoves {
+		r.PhaseMoves[phase] += n
+		r.PhaseTime[phase] += other.PhaseTime[phase]
+	}
+}
+
+func (r *ClockReport) AverageTime() time.Duration {
+	if r.Moves == 0 {
+		return 0
+	}
+	return r.TotalTime / time.Duration(r.Moves)
+}
+
+func (r *ClockReport) PhaseAverage(phase string) time.Duration {
+	if r.PhaseMoves[phase] == 0 {
+		return 0
+	}
+	return r.PhaseTime[phase] / time.Duration(r.PhaseMoves[phase])
+}
+
+// TimeTroubleBlunderRate is the share of moves made in time trouble that
+// were blunders.
+func (r *ClockReport) TimeTroubleBlunderRate() float64 {
+	if r.TimeTroubleMoves == 0 {
+		return 0
+	}
+	return float64(r.TimeTroubleBlunders) / float64(r.TimeTroubleMoves)
+}
+
+// BlunderRate is the share of moves made with enough time that were blunders.
+func (r *ClockReport) BlunderRate() float64 {
+	n := r.Moves - r.TimeTroubleMoves
+	if n == 0 {
+		return 0
+	}
+	return float64(r.Blunders-r.TimeTroubleBlunders) / float64(n)
+}
+
+type GameClockReport struct {
+	GameID      string
+	TimeControl string
+	Flagged     string
+	Moves       []MoveTime
+	White       ClockReport
+	Black       ClockReport
+}
+
+func (g *GameClockReport) Player(c Color) *ClockReport {
+	if c == White {
+		return &g.White
+	}
+	return &g.Black
+}
+
+// AnalyzeClocks reports how each player used their clock. The clocks of a
+// game export hold the time left after each move, in centiseconds.
+func AnalyzeClocks(game *Game) (*GameClockReport, error) {
+	if len(game.Clocks) == 0 {
+		return nil, ErrNoClocks
+	}
+
+	report := &GameClockReport{
+		GameID:      game.ID,
+		TimeControl: gameTimeControl(game),
+		White:       newClockReport(),
+		Black:       newClockReport(),
+	}
+	if game.Status == "outoftime" && game.Winner != "" {
+		report.Flagged = White.String()
+		if game.Winner == White.String() {
+			report.Flagged = Black.String()
+		}
+	}
+
+	middlegame, endgame := 0, 0
+	if replayed, _ := ReplayGame(game); replayed != nil {
+		middlegame, endgame = replayed.Phases()
+	}
+	var judgments []MoveQuality
+	if acc, err := AnalyzeAccuracy(game); err == nil {
+		judgments = acc.Moves
+	}
+
+	start := gameStartColor(game)
+	initial := time.Duration(game.Clock.Initial) * time.Second
+	increment := time.Duration(game.Clock.Increment) * time.Second
+	for i, cs := range game.Clocks {
+		remaining := time.Duration(cs) * 10 * time.Millisecond
+		before := initial
+		if i >= 2 {
+			before = time.Duration(game.Clocks[i-2]) * 10 * time.Millisecond
+		}
+		spent := before - remaining
+		if i >= 2 {
+			spent += increment
+		}
+		if spent < 0 {
+			spent = 0
+		}
+
+		color := start
+		if i%2 == 1 {
+			color = start.Other()
+		}
+		m := MoveTime{
+			Ply:         i + 1,
+			Color:       color,
+			Spent:       spent,
+			Remaining:   remaining,
+			Phase:       phaseAt(i+1, middlegame, endgame),
+			TimeTrouble: before < TimeTroubleThreshold,
+		}
+		if i < len(judgments) {
+			m.Judgment = judgments[i].Judgment
+		}
+		report.Moves = append(report.Moves, m)
+		report.Player(color).add(m)
+	}
+	return report, nil
+}
+
+type FlagStats struct {
+	Games   int
+	Flagged int
+}
+
+func (f FlagStats) Rate() float64 {
+	if f.Games == 0 {
+		return 0
+	}
+	return float64(f.Flagged) / float64(f.Games)
+}
+
+// UserClockReport aggregates the clock usage of one user over many games.
+type UserClockReport struct {
+	User  string
+	Games int
+	ClockReport
+	FlagLosses map[string]FlagStats
+}
+
+func NewUserClockReport(user string) *UserClockReport {
+	return &UserClockReport{
+		User:        user,
+		ClockReport: newClockReport(),
+		FlagLosses:  make(map[string]FlagStats),
+	}
+}
+
+// Add includes a game of the user in the report. It fails for games the
+// user did not play or that have no clock times.
+func (u *UserClockReport) Add(game *Game) error {
+	side, ok := playerColor(game, u.User)
+	if !ok {
+		return errors.New("user did not play in game " + game.ID)
+	}
+	report, err := AnalyzeClocks(game)
+	if err != nil {
+		return err
+	}
+
+	u.Games++
+	u.merge(*report.Player(side))
+	stats := u.FlagLosses[report.TimeControl]
+	stats.Games++
+	if report.Flagged == side.String() {
+		stats.Flagged++
+	}
+	u.FlagLosses[report.TimeControl] = stats
+	return nil
+}
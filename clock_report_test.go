@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const clockGames = `
+{"id":"g1","status":"outoftime","winner":"black","moves":"e4 e5 Nf3 Nc6 Bc4 Bc5 Qe2","clock":{"initial":180,"increment":2},"clocks":[18000,18000,17500,17000,900,16000,500],"players":{"white":{"user":{"name":"Alice","id":"alice"}},"black":{"user":{"name":"Bob","id":"bob"}}}}
+{"id":"g2","status":"outoftime","winner":"white","moves":"e4 e5 Nf3 Nc6 Bc4 Bc5 Qe2","clock":{"initial":180,"increment":2},"clocks":[18000,18000,17000,17000,16000,16000,15000],"players":{"white":{"user":{"name":"Alice","id":"alice"}},"black":{"user":{"name":"Bob","id":"bob"}}}}
+{"id":"g3","status":"mate","winner":"white","moves":"e4 e5 Nf3 Nc6 Bc4 Bc5 Qe2","clock":{"initial":180,"increment":2},"clocks":[18000,18000,17000,17000,16000,16000,15000],"players":{"white":{"user":{"name":"Alice","id":"alice"}},"black":{"user":{"name":"Bob","id":"bob"}}}}
+{"id":"g4","status":"draw","moves":"e4 e5 Nf3 Nc6 Bc4 Bc5 Qe2","clock":{"initial":180,"increment":2},"clocks":[18000],"players":{"white":{"user":{"name":"carol","id":"carol"}},"black":{"user":{"name":"Bob","id":"bob"}}}}
+`
+
+func TestAnalyzeClocks(t *testing.T) {
+	game := &mustParseGames(clockGames)[0]
+	game.Analysis = []MoveAnalysis{{Eval: 20}, {Eval: 20}, {Eval: 20}, {Eval: 20}, {Eval: 20}, {Eval: 20}, {Eval: -400}}
+
+	report, err := AnalyzeClocks(game)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.TimeControl != "180+2" || report.Flagged != "white" || len(report.Moves) != 7 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if m := report.Moves[4]; m.Spent != 168*time.Second || m.TimeTrouble || m.Color != White {
+		t.Errorf("Unexpected move %+v", m)
+	}
+	if m := report.Moves[6]; m.Spent != 6*time.Second || !m.TimeTrouble || m.Judgment != JudgmentBlunder {
+		t.Errorf("Unexpected move %+v", m)
+	}
+	if report.White.Moves != 4 || report.White.TimeTroubleMoves != 1 || report.White.TimeTroubleBlunderRate() != 1 || report.White.BlunderRate() != 0 {
+		t.Errorf("Unexpected white report %+v", report.White)
+	}
+	if avg := report.Black.AverageTime(); avg != 8*time.Second {
+		t.Errorf("Unexpected black average %v", avg)
+	}
+	if avg := report.White.PhaseAverage(PhaseOpening); avg != report.White.AverageTime() {
+		t.Errorf("Expected every move to be in the opening, got %v", avg)
+	}
+
+	if _, err := AnalyzeClocks(&Game{}); err != ErrNoClocks {
+		t.Errorf("Expected ErrNoClocks, got %v", err)
+	}
+}
+
+func TestPhases(t *testing.T) {
+	game := Game{Moves: "e4 e5 Nf3 Nc6 Bb5 a6 Bxc6 dxc6 O-O Bg4 h3 Bxf3 Qxf3 Qf6 Qxf6 Nxf6"}
+	replayed, _ := ReplayGame(&game)
+	if middlegame, endgame := replayed.Phases(); middlegame != 13 || endgame != 0 {
+		t.Errorf("Expected the middlegame at ply 13, got %d %d", middlegame, endgame)
+	}
+
+	replayed, _ = ReplayGame(&Game{InitialFen: "r3k3/8/8/8/8/8/8/R3KB2 w - - 0 1", Moves: "Ra7 Rxa7"})
+	if middlegame, endgame := replayed.Phases(); middlegame != 1 || endgame != 1 {
+		t.Errorf("Expected an endgame from the first ply, got %d %d", middlegame, endgame)
+	}
+}
+
+func TestUserClockReport(t *testing.T) {
+	report := NewUserClockReport("alice")
+	games := mustParseGames(clockGames)
+	for i := range games[:3] {
+		if err := report.Add(&games[i]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if report.Games != 3 || report.Moves != 12 || report.TimeTroubleMoves != 1 {
+		t.Errorf("Unexpected report %+v", report.ClockReport)
+	}
+	if flags := report.FlagLosses["180+2"]; flags.Games != 3 || flags.Flagged != 1 {
+		t.Errorf("Unexpected flag stats %+v", flags)
+	}
+
+	if err := report.Add(&games[3]); err == nil {
+		t.Errorf("Expected an error for a game alice did not play")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+type DrawState struct {
+	Repetitions          int
+	ThreefoldRepetition  bool
+	FivefoldRepetition   bool
+	FiftyMoves           bool
+	SeventyFiveMoves     bool
+	InsufficientMaterial bool
+}
+
+// Claimable reports whether a player may claim a draw in this position.
+func (d DrawState) Claimable() bool {
+	return d.ThreefoldRepetition || d.FiftyMoves
+}
+
+// Automatic reports whether the game is drawn without any claim.
+func (d DrawState) Automatic() bool {
+	return d.FivefoldRepetition || d.SeventyFiveMoves || d.InsufficientMaterial
+}
+
+type PositionHistory struct {
+	Hashes []uint64
+	counts map[uint64]int
+}
+
+func NewPositionHistory(initial *Position) *PositionHistory {
+	h := &PositionHistory{counts: make(map[uint64]int)}
+	h.Push(initial)
+	return h
+}
+
+// Push records a position and returns how many times it has occurred so far.
+func (h *PositionHistory) Push(p *Position) int {
+	hash := p.Hash()
+	h.Hashes = append(h.Hashes, hash)
+	h.counts[hash]++
+	return h.counts[hash]
+}
+
+func (h *PositionHistory) Count(hash uint64) int {
+	return h.counts[hash]
+}
+
+func (p *Position) DrawState(repetitions int) DrawState {
+	d := DrawState{Repetitions: repetitions}
+	if p.Outcome().Winner != "" {
+		return d
+	}
+	d.ThreefoldRepetition = repetitions >= 3
+	d.FivefoldRepetition = repetitions >= 5
+	d.FiftyMoves = p.HalfmoveClock >= 100
+	d.SeventyFiveMoves = p.HalfmoveClock >= 150
+	d.InsufficientMaterial = p.IsInsufficientMaterial()
+	return d
+}
+
+type materialCount struct {
+	pieces       [2][7]int
+	bishopColors [2][2]int
+}
+
+func (p *Position) material() materialCount {
+	var m materialCount
+	for i, pc := range p.Board {
+		if pc.Type == NoPieceType {
+			continue
+		}
+		m.pieces[pc.Color][pc.Type]++
+		if pc.Type == Bishop {
+			sq := Square(i)
+			m.bishopColors[pc.Color][(sq.File()+sq.Rank())%2]++
+		}
+	}
+	return m
+}
+
+func (m materialCount) count(c Color, types ...PieceType) int {
+	n := 0
+	for _, pt := range types {
+		n += m.pieces[c][pt]
+	}
+	return n
+}
+
+func (m materialCount) kingOnly(c Color) bool {
+	return m.count(c, Pawn, Knight, Bishop, Rook, Queen) == 0
+}
+
+// IsInsufficientMaterial reports whether neither side can win by any
+// sequence of legal moves, following the rules Lichess applies per variant.
+func (p *Position) IsInsufficientMaterial() bool {
+	m := p.material()
+	switch p.Variant {
+	case VariantCrazyhouse, VariantKingOfTheHill, VariantRacingKings, VariantHorde:
+		return false
+	case VariantThreeCheck:
+		return m.kingOnly(White) && m.kingOnly(Black)
+	case VariantAtomic:
+		for _, c := range []Color{White, Black} {
+			if m.kingOnly(c) && m.count(c.Other(), Pawn, Rook, Queen) == 0 && m.count(c.Other(), Knight, Bishop) <= 1 {
+				return true
+			}
+		}
+		return false
+	case VariantAntichess:
+		if m.count(White, Pawn, Knight, Rook, Queen, King) > 0 || m.count(Black, Pawn, Knight, Rook, Queen, King) > 0 {
+			return false
+		}
+		if m.pieces[White][Bishop] == 0 || m.pieces[Black][Bishop] == 0 {
+			return false
+		}
+		for shade := 0; shade < 2; shade++ {
+			if m.bishopColors[White][shade] == 0 && m.bishopColors[Black][1-shade] == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if m.count(White, Pawn, Rook, Queen) > 0 || m.count(Black, Pawn, Rook, Queen) > 0 {
+		return false
+	}
+	minors := m.count(White, Knight, Bishop) + m.count(Black, Knight, Bishop)
+	if minors <= 1 {
+		return true
+	}
+	if m.count(White, Knight) > 0 || m.count(Black, Knight) > 0 {
+		return false
+	}
+	light := m.bishopColors[White][0] + m.bishopColors[Black][0]
+	dark := m.bishopColors[White][1] + m.bishopColors[Black][1]
+	return light == 0 || dark == 0
+}
+
+// HasInsufficientMaterial reports whether c can no longer checkmate,
+// which turns a loss on time into a draw.
+func (p *Position) HasInsufficientMaterial(c Color) bool {
+	m := p.material()
+	switch p.Variant {
+	case VariantCrazyhouse, VariantKingOfTheHill, VariantRacingKings, VariantAntichess:
+		return false
+	case VariantHorde:
+		return c == White && m.count(White, Pawn, Knight, Bishop, Rook, Queen, King) == 0
+	case VariantThreeCheck, VariantAtomic:
+		return m.kingOnly(c)
+	}
+
+	if m.kingOnly(c) {
+		return true
+	}
+	other := c.Other()
+	if m.count(c, Pawn, Rook, Queen) > 0 {
+		return false
+	}
+	if m.pieces[c][Bishop] == 0 && m.pieces[c][Knight] == 1 {
+		return m.count(other, Pawn, Knight, Bishop, Rook) == 0
+	}
+	if m.pieces[c][Knight] == 0 {
+		if m.count(other, Pawn, Knight) > 0 {
+			return false
+		}
+		light := m.bishopColors[c][0] + m.bishopColors[other][0]
+		dark := m.bishopColors[c][1] + m.bishopColors[other][1]
+		return light == 0 || dark == 0
+	}
+	return false
+}
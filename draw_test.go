@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestRepetition(t *testing.T) {
+	game := Game{Moves: "Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8"}
+	replayed, err := ReplayGame(&game)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := replayed.Plies[7].Draw.Repetitions; n != 3 {
+		t.Errorf("Expected the starting position to occur 3 times, got %d", n)
+	}
+	if !replayed.Plies[7].Draw.ThreefoldRepetition || replayed.Plies[6].Draw.Claimable() {
+		t.Errorf("Expected a threefold repetition claim only at ply 8")
+	}
+	if got := replayed.FirstClaimableDraw(); got != 8 {
+		t.Errorf("Expected the first draw claim at ply 8, got %d", got)
+	}
+	if d := replayed.DrawState(); !d.FivefoldRepetition || !d.Automatic() {
+		t.Errorf("Expected an automatic fivefold repetition draw, got %+v", d)
+	}
+
+	crazyhouse := Game{Variant: VariantCrazyhouse, Moves: "e4 d5 exd5 Qxd5 Nc3 Qd8 Nb1 Qd5 Nc3 Qd8 Nb1 Qd5"}
+	replayed, err = ReplayGame(&crazyhouse)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := replayed.DrawState().Repetitions; n != 3 {
+		t.Errorf("Expected 3 repetitions with equal pockets, got %d", n)
+	}
+}
+
+func TestFiftyMoveRule(t *testing.T) {
+	pos, _ := ParseFen("4k3/8/8/8/8/8/8/R3K3 w - - 99 80")
+	plies, err := Replay(pos.Fen(), "Ra2 Kd7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d := plies[0].Draw; !d.FiftyMoves || !d.Claimable() || d.Automatic() {
+		t.Errorf("Expected a claimable fifty-move draw, got %+v", d)
+	}
+
+	pos, _ = ParseFen("7k/8/6K1/8/8/8/8/R7 w - - 149 120")
+	plies, err = Replay(pos.Fen(), "Ra8#")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d := plies[0].Draw; d.SeventyFiveMoves || d.FiftyMoves {
+		t.Errorf("Expected checkmate to take precedence over the move rules, got %+v", d)
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	tests := []struct {
+		variant string
+		fen     string
+		want    bool
+	}{
+		{VariantStandard, "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{VariantStandard, "4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true},
+		{VariantStandard, "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{VariantStandard, "4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{VariantStandard, "4kn2/8/8/8/8/8/8/4KN2 w - - 0 1", false},
+		{VariantStandard, "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+		{VariantCrazyhouse, "4k3/8/8/8/8/8/8/4K3[] w - - 0 1", false},
+		{VariantKingOfTheHill, "4k3/8/8/8/8/8/8/4K3 w - - 0 1", false},
+		{VariantAtomic, "4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true},
+		{VariantAtomic, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", false},
+		{VariantThreeCheck, "4k3/8/8/8/8/8/8/4KN2 w - - 3+3 0 1", false},
+		{VariantAntichess, "5b2/8/8/8/8/8/8/5B2 w - - 0 1", true},
+		{VariantAntichess, "4b3/8/8/8/8/8/8/5B2 w - - 0 1", false},
+	}
+	for _, test := range tests {
+		pos, err := ParseVariantFen(test.variant, test.fen)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", test.fen, err)
+		}
+		if got := pos.IsInsufficientMaterial(); got != test.want {
+			t.Errorf("%s %s: expected %v, got %v", test.variant, test.fen, test.want, got)
+		}
+	}
+
+	pos, _ := ParseFen("4k3/8/8/8/8/8/8/3QKN2 w - - 0 1")
+	if !pos.HasInsufficientMaterial(Black) || pos.HasInsufficientMaterial(White) {
+		t.Errorf("Expected only black to lack mating material")
+	}
+	pos, _ = ParseFen("4k3/8/8/8/8/8/4p3/4KN2 w - - 0 1")
+	if pos.HasInsufficientMaterial(White) {
+		t.Errorf("Expected a knight to mate against a pawn")
+	}
+}
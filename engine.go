@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEngineNotRunning = errors.New("engine is not running")
+	ErrEngineCrashed    = errors.New("engine process exited unexpectedly")
+	ErrEngineTimeout    = errors.New("engine did not respond in time")
+)
+
+type EngineOption struct {
+	Name    string
+	Type    string
+	Default string
+	Min     string
+	Max     string
+	Vars    []string
+}
+
+type EngineInfo struct {
+	Depth      int
+	SelDepth   int
+	MultiPV    int
+	Score      int
+	Mate       int
+	LowerBound bool
+	UpperBound bool
+	Nodes      int64
+	NPS        int64
+	Time       time.Duration
+	HashFull   int
+	TBHits     int64
+	CurrMove   string
+	PV         []string
+	String     string
+}
+
+type SearchParams struct {
+	Depth    int
+	Nodes    int64
+	MoveTime time.Duration
+	MultiPV  int
+	WTime    time.Duration
+	BTime    time.Duration
+	WInc     time.Duration
+	BInc     time.Duration
+	Infinite bool
+}
+
+func (p SearchParams) command() string {
+	var sb strings.Builder
+	sb.WriteString("go")
+	if p.Depth > 0 {
+		fmt.Fprintf(&sb, " depth %d", p.Depth)
+	}
+	if p.Nodes > 0 {
+		fmt.Fprintf(&sb, " nodes %d", p.Nodes)
+	}
+	if p.MoveTime > 0 {
+		fmt.Fprintf(&sb, " movetime %d", p.MoveTime.Milliseconds())
+	}
+	if p.WTime > 0 || p.BTime > 0 {
+		fmt.Fprintf(&sb, " wtime %d btime %d winc %d binc %d", p.WTime.Milliseconds(), p.BTime.Milliseconds(), p.WInc.Milliseconds(), p.BInc.Milliseconds())
+	}
+	if p.Infinite {
+		sb.WriteString(" infinite")
+	}
+	return sb.String()
+}
+
+type SearchResult struct {
+	BestMove string
+	Ponder   string
+	// Lines holds the deepest info of each principal variation, best first.
+	Lines []EngineInfo
+}
+
+// Engine drives a chess engine process over the UCI protocol. Options set
+// with SetOption are applied again when the process is restarted.
+type Engine struct {
+	Path string
+	Args []string
+	// Timeout bounds the answers to commands and how long a search may
+	// overrun its move time.
+	Timeout time.Duration
+	// SearchTimeout bounds the searches by depth, nodes or clock, which
+	// the engine may otherwise run forever when it hangs.
+	SearchTimeout time.Duration
+
+	Name    string
+	Author  string
+	Options map[string]EngineOption
+
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	settings []string
+	values   map[string]string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan string
+	quit     chan struct{}
+}
+
+func NewEngine(path string, args ...string) *Engine {
+	return &Engine{
+		Path:          path,
+		Args:          args,
+		Timeout:       10 * time.Second,
+		SearchTimeout: 5 * time.Minute,
+		values:        make(map[string]string),
+	}
+}
+
+// Start spawns the engine and performs the UCI handshake.
+func (e *Engine) Start() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.start()
+}
+
+func (e *Engine) start() error {
+	if e.cmd != nil {
+		e.kill()
+	}
+
+	cmd := exec.Command(e.Path, e.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	lines := make(chan string, 256)
+	quit := make(chan struct{})
+	go func() {
+		defer close(lines)
+		defer cmd.Wait()
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-quit:
+				return
+			}
+		}
+	}()
+	e.setProcess(cmd, stdin)
+	e.lines, e.quit = lines, quit
+	e.Options = make(map[string]EngineOption)
+
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+	err = e.readUntil(e.Timeout, func(line string) bool {
+		switch {
+		case strings.HasPrefix(line, "id name "):
+			e.Name = strings.TrimPrefix(line, "id name ")
+		case strings.HasPrefix(line, "id author "):
+			e.Author = strings.TrimPrefix(line, "id author ")
+		case strings.HasPrefix(line, "option "):
+			if opt, ok := parseEngineOption(line); ok {
+				e.Options[opt.Name] = opt
+			}
+		}
+		return line == "uciok"
+	})
+	if err != nil {
+		e.kill()
+		return err
+	}
+
+	for _, name := range e.settings {
+		if err := e.send(setOptionCommand(name, e.values[name])); err != nil {
+			e.kill()
+			return err
+		}
+	}
+	if err := e.isReady(); err != nil {
+		e.kill()
+		return err
+	}
+	return nil
+}
+
+func (e *Engine) send(cmd string) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	if e.stdin == nil {
+		return ErrEngineNotRunning
+	}
+	_, err := io.WriteString(e.stdin, cmd+"\n")
+	if err != nil {
+		return ErrEngineCrashed
+	}
+	return nil
+}
+
+// readUntil passes engine output to fn until it returns true.
+func (e *Engine) readUntil(timeout time.Duration, fn func(string) bool) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				e.setProcess(nil, nil)
+				return ErrEngineCrashed
+			}
+			if fn(strings.TrimSpace(line)) {
+				return nil
+			}
+		case <-deadline:
+			return ErrEngineTimeout
+		}
+	}
+}
+
+func (e *Engine) isReady() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.readUntil(e.Timeout, func(line string) bool {
+		return line == "readyok"
+	})
+}
+
+func (e *Engine) setProcess(cmd *exec.Cmd, stdin io.WriteCloser) {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	e.cmd, e.stdin = cmd, stdin
+}
+
+func (e *Engine) kill() {
+	if e.cmd != nil && e.cmd.Process != nil {
+		e.cmd.Process.Kill()
+		close(e.quit)
+	}
+	e.setProcess(nil, nil)
+}
+
+func setOptionCommand(name, value string) string {
+	if value == "" {
+		return "setoption name " + name
+	}
+	return "setoption name " + name + " value " + value
+}
+
+// SetOption sets an engine option and remembers it for restarts. An empty
+// value presses a button option.
+func (e *Engine) SetOption(name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.values[name]; !ok {
+		e.settings = append(e.settings, name)
+	}
+	e.values[name] = value
+	if err := e.send(setOptionCommand(name, value)); err != nil {
+		return err
+	}
+	return e.isReady()
+}
+
+// uciVariants maps Lichess variant keys to the UCI_Variant values of
+// multi-variant engines.
+var uciVariants = map[string]string{
+	VariantAntichess:     "antichess",
+	VariantAtomic:        "atomic",
+	VariantCrazyhouse:    "crazyhouse",
+	VariantHorde:         "horde",
+	VariantKingOfTheHill: "kingofthehill",
+	VariantRacingKings:   "racingkings",
+	VariantThreeCheck:    "3check",
+}
+
+// SetVariant sets the UCI_Variant and UCI_Chess960 options for the variant,
+// sending only the ones that changed. It returns ErrUnsupportedVariant if
+// the engine does not have the options the variant needs.
+func (e *Engine) SetVariant(variant string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	uciVariant, chess960 := "chess", "false"
+	switch variant {
+	case "", VariantStandard, VariantFromPosition:
+	case VariantChess960:
+		chess960 = "true"
+	default:
+		uciVariant = uciVariants[variant]
+	}
+	_, hasChess960 := e.Options["UCI_Chess960"]
+	option, hasVariant := e.Options["UCI_Variant"]
+	if chess960 == "true" && !hasChess960 {
+		return ErrUnsupportedVariant
+	}
+	if uciVariant != "chess" && (!hasVariant || !containsFold(option.Vars, uciVariant)) {
+		return ErrUnsupportedVariant
+	}
+
+	changed := false
+	set := func(name, value string) error {
+		if current, ok := e.values[name]; ok && current == value {
+			return nil
+		}
+		if _, ok := e.values[name]; !ok {
+			e.settings = append(e.settings, name)
+		}
+		e.values[name] = value
+		changed = true
+		return e.send(setOptionCommand(name, value))
+	}
+	if hasVariant {
+		if err := set("UCI_Variant", uciVariant); err != nil {
+			return err
+		}
+	}
+	if hasChess960 {
+		if err := set("UCI_Chess960", chess960); err != nil {
+			return err
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return e.isReady()
+}
+
+func (e *Engine) NewGame() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.isReady()
+}
+
+func positionCommand(fen string, moves []string) string {
+	cmd := "position startpos"
+	if fen != "" && fen != StartingFen {
+		cmd = "position fen " + fen
+	}
+	if len(moves) > 0 {
+		cmd += " moves " + strings.Join(moves, " ")
+	}
+	return cmd
+}
+
+// Search sets up the position, given as a FEN (empty for the starting
+// position) and UCI moves, and searches it. Each info line is passed to
+// onInfo if it is not nil. A search that has not ended within the engine
+// timeout after its move time, or within the search timeout when it has no
+// move time, is stopped, and the engine is killed if it does not answer to
+// stop either. Infinite searches run until Stop is called.
+func (e *Engine) Search(fen string, moves []string, params SearchParams, onInfo func(EngineInfo)) (*SearchResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	multipv := params.MultiPV
+	if multipv < 1 {
+		multipv = 1
+	}
+	if _, ok := e.Options["MultiPV"]; ok {
+		if err := e.send(setOptionCommand("MultiPV", strconv.Itoa(multipv))); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.send(positionCommand(fen, moves)); err != nil {
+		return nil, err
+	}
+	if err := e.send(params.command()); err != nil {
+		return nil, err
+	}
+
+	var timeout time.Duration
+	switch {
+	case params.Infinite:
+	case params.MoveTime > 0 && e.Timeout > 0:
+		timeout = params.MoveTime + e.Timeout
+	case params.MoveTime <= 0:
+		timeout = e.SearchTimeout
+	}
+
+	result := &SearchResult{Lines: make([]EngineInfo, multipv)}
+	read := func(line string) bool {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return false
+		}
+		switch fields[0] {
+		case "info":
+			info, ok := ParseEngineInfo(line)
+			if !ok {
+				return false
+			}
+			if onInfo != nil {
+				onInfo(info)
+			}
+			if len(info.PV) > 0 && info.MultiPV >= 1 && info.MultiPV <= multipv {
+				result.Lines[info.MultiPV-1] = info
+			}
+		case "bestmove":
+			if len(fields) > 1 {
+				result.BestMove = fields[1]
+			}
+			if len(fields) > 3 && fields[2] == "ponder" {
+				result.Ponder = fields[3]
+			}
+			return true
+		}
+		return false
+	}
+
+	err := e.readUntil(timeout, read)
+	if err == ErrEngineTimeout {
+		e.send("stop")
+		if err = e.readUntil(e.Timeout, read); err == ErrEngineTimeout {
+			e.kill()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := result.Lines[:0]
+	for _, l := range result.Lines {
+		if len(l.PV) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	result.Lines = lines
+	return result, nil
+}
+
+// Stop asks the engine to end the current search, which then returns.
+func (e *Engine) Stop() error {
+	return e.send("stop")
+}
+
+// Analyze searches like Search but restarts the engine and retries once if
+// the process is not running or crashes during the search.
+func (e *Engine) Analyze(fen string, moves []string, params SearchParams, onInfo func(EngineInfo)) (*SearchResult, error) {
+	result, err := e.Search(fen, moves, params, onInfo)
+	if err != ErrEngineCrashed && err != ErrEngineNotRunning && err != ErrEngineTimeout {
+		return result, err
+	}
+	if err := e.Start(); err != nil {
+		return nil, err
+	}
+	return e.Search(fen, moves, params, onInfo)
+}
+
+// Quit asks the engine to exit and kills it if it does not within the
+// timeout.
+func (e *Engine) Quit() error {
+	// A search in progress holds the lock: stop it first, and kill the
+	// process if it still holds the lock after the timeout.
+	e.send("stop")
+	locked := make(chan struct{})
+	go func() {
+		e.mu.Lock()
+		close(locked)
+	}()
+	var deadline <-chan time.Time
+	if e.Timeout > 0 {
+		timer := time.NewTimer(e.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	select {
+	case <-locked:
+	case <-deadline:
+		e.writeMu.Lock()
+		if e.cmd != nil && e.cmd.Process != nil {
+			e.cmd.Process.Kill()
+		}
+		e.writeMu.Unlock()
+		<-locked
+	}
+	defer e.mu.Unlock()
+	if e.cmd == nil {
+		return nil
+	}
+	e.send("quit")
+	err := e.readUntil(e.Timeout, func(string) bool { return false })
+	if err == ErrEngineCrashed {
+		return nil
+	}
+	e.kill()
+	return err
+}
+
+func parseEngineOption(line string) (EngineOption, bool) {
+	fields := strings.Fields(line)
+	var opt EngineOption
+	keys := map[string]bool{"name": true, "type": true, "default": true, "min": true, "max": true, "var": true}
+	for i := 1; i < len(fields); {
+		key := fields[i]
+		j := i + 1
+		for j < len(fields) && !keys[fields[j]] {
+			j++
+		}
+		value := strings.Join(fields[i+1:j], " ")
+		switch key {
+		case "name":
+			opt.Name = value
+		case "type":
+			opt.Type = value
+		case "default":
+			opt.Default = value
+		case "min":
+			opt.Min = value
+		case "max":
+			opt.Max = value
+		case "var":
+			opt.Vars = append(opt.Vars, value)
+		}
+		i = j
+	}
+	return opt, opt.Name != ""
+}
+
+// ParseEngineInfo parses a UCI "info" line. Scores are from the point of
+// view of the side to move.
+func ParseEngineInfo(line string) (EngineInfo, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return EngineInfo{}, false
+	}
+	info := EngineInfo{MultiPV: 1}
+	atoi := func(i int) int64 {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.ParseInt(fields[i], 10, 64)
+		return n
+	}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			info.Depth = int(atoi(i + 1))
+			i++
+		case "seldepth":
+			info.SelDepth = int(atoi(i + 1))
+			i++
+		case "multipv":
+			info.MultiPV = int(atoi(i + 1))
+			i++
+		case "nodes":
+			info.Nodes = atoi(i + 1)
+			i++
+		case "nps":
+			info.NPS = atoi(i + 1)
+			i++
+		case "time":
+			info.Time = time.Duration(atoi(i+1)) * time.Millisecond
+			i++
+		case "hashfull":
+			info.HashFull = int(atoi(i + 1))
+			i++
+		case "tbhits":
+			info.TBHits = atoi(i + 1)
+			i++
+		case "currmove":
+			if i+1 < len(fields) {
+				info.CurrMove = fields[i+1]
+			}
+			i++
+		case "score":
+			for i+1 < len(fields) {
+				switch fields[i+1] {
+				case "cp":
+					info.Score = int(atoi(i + 2))
+					i += 2
+					continue
+				case "mate":
+					info.Mate = int(atoi(i + 2))
+					i += 2
+					continue
+				case "lowerbound":
+					info.LowerBound = true
+					i++
+					continue
+				case "upperbound":
+					info.UpperBound = true
+					i++
+					continue
+				}
+				break
+			}
+		case "pv":
+			info.PV = append([]string(nil), fields[i+1:]...)
+			i = len(fields)
+		case "string":
+			info.String = strings.Join(fields[i+1:], " ")
+			i = len(fields)
+		}
+	}
+	return info, true
+}
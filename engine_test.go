@@ -0,0 +1,325 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fakeEngineSource = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var pvs = []string{"e2e4 e7e5 g1f3", "d2d4 d7d5", "c2c4 e7e5"}
+
+func main() {
+	hang, crashMarker := false, ""
+	var script []string
+	for i := 1; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "-hang":
+			hang = true
+		case "-crash-once":
+			crashMarker = os.Args[i+1]
+		case "-script":
+			data, _ := os.ReadFile(os.Args[i+1])
+			script = strings.Split(strings.TrimSpace(string(data)), "\n")
+		}
+	}
+
+	options := map[string]string{"Hash": "16", "MultiPV": "1"}
+	pending, plies := false, 0
+	report := func(depth int) {
+		multipv, _ := strconv.Atoi(options["MultiPV"])
+		for d := 1; d <= depth; d++ {
+			for pv := 1; pv <= multipv; pv++ {
+				fmt.Printf("info depth %d seldepth %d multipv %d score cp %d nodes %d nps 100000 time %d pv %s\n", d, d+2, pv, 40-10*pv+d, 1000*d, d, pvs[pv-1])
+			}
+		}
+		fmt.Printf("info string hash %s\n", options["Hash"])
+		fmt.Println("bestmove e2e4 ponder e7e5")
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			fmt.Println("id name FakeFish 1.0")
+			fmt.Println("id author Test Suite")
+			fmt.Println("option name Hash type spin default 16 min 1 max 1024")
+			fmt.Println("option name MultiPV type spin default 1 min 1 max 3")
+			fmt.Println("option name Style type combo default Normal var Solid var Normal var Risky")
+			fmt.Println("option name UCI_Chess960 type check default false")
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "setoption":
+			line := strings.Join(fields[2:], " ")
+			if i := strings.Index(line, " value "); i >= 0 {
+				options[line[:i]] = line[i+7:]
+			}
+		case "position":
+			plies = 0
+			for i, f := range fields {
+				if f == "moves" {
+					plies = len(fields) - i - 1
+				}
+			}
+		case "go":
+			if hang {
+				continue
+			}
+			if script != nil {
+				line := strings.Fields(script[plies])
+				fmt.Printf("info depth 1 score %s %s pv %s\n", line[0], line[1], strings.Join(line[2:], " "))
+				fmt.Printf("bestmove %s\n", line[2])
+				continue
+			}
+			if crashMarker != "" {
+				if _, err := os.Stat(crashMarker); err != nil {
+					os.WriteFile(crashMarker, nil, 0644)
+					fmt.Println("info depth 1 score cp 10 pv e2e4")
+					os.Exit(3)
+				}
+			}
+			depth := 3
+			for i := 1; i+1 < len(fields); i++ {
+				n, _ := strconv.Atoi(fields[i+1])
+				switch fields[i] {
+				case "depth":
+					depth = n
+				case "movetime":
+					time.Sleep(time.Duration(n) * time.Millisecond)
+				}
+			}
+			if fields[len(fields)-1] == "infinite" {
+				pending = true
+				continue
+			}
+			report(depth)
+		case "stop":
+			if pending && !hang {
+				pending = false
+				report(2)
+			}
+		case "quit":
+			return
+		}
+	}
+}
+`
+
+func buildFakeEngine(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not available to build the fake engine")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "fakeengine.go")
+	if err := os.WriteFile(src, []byte(fakeEngineSource), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bin := filepath.Join(dir, "fakeengine")
+	cmd := exec.Command("go", "build", "-o", bin, src)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Building the fake engine failed: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestParseEngineInfo(t *testing.T) {
+	info, ok := ParseEngineInfo("info depth 20 seldepth 31 multipv 2 score mate -3 upperbound nodes 123456 nps 987654 hashfull 12 tbhits 4 time 1500 pv e7e5 g1f3 b8c6")
+	if !ok || info.Depth != 20 || info.SelDepth != 31 || info.MultiPV != 2 || info.Mate != -3 || !info.UpperBound {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if info.Nodes != 123456 || info.NPS != 987654 || info.HashFull != 12 || info.TBHits != 4 || info.Time != 1500*time.Millisecond {
+		t.Errorf("Unexpected counters %+v", info)
+	}
+	if strings.Join(info.PV, " ") != "e7e5 g1f3 b8c6" {
+		t.Errorf("Unexpected pv %v", info.PV)
+	}
+
+	info, _ = ParseEngineInfo("info depth 5 currmove e2e4 currmovenumber 1 score cp -25 lowerbound")
+	if info.Score != -25 || !info.LowerBound || info.CurrMove != "e2e4" || info.MultiPV != 1 {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if info, _ := ParseEngineInfo("info string NNUE evaluation enabled"); info.String != "NNUE evaluation enabled" {
+		t.Errorf("Unexpected string %q", info.String)
+	}
+	if _, ok := ParseEngineInfo("bestmove e2e4"); ok {
+		t.Errorf("Expected only info lines to parse")
+	}
+}
+
+func TestEngine(t *testing.T) {
+	engine := NewEngine(buildFakeEngine(t))
+	engine.Timeout = 5 * time.Second
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer engine.Quit()
+
+	if engine.Name != "FakeFish 1.0" || engine.Author != "Test Suite" {
+		t.Errorf("Unexpected engine id %q %q", engine.Name, engine.Author)
+	}
+	if opt := engine.Options["Style"]; opt.Type != "combo" || opt.Default != "Normal" || len(opt.Vars) != 3 {
+		t.Errorf("Unexpected option %+v", opt)
+	}
+	if err := engine.SetOption("Hash", "64"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := engine.NewGame(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var infos []EngineInfo
+	result, err := engine.Search("", []string{"e2e4"}, SearchParams{Depth: 4, MultiPV: 2}, func(info EngineInfo) {
+		infos = append(infos, info)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.BestMove != "e2e4" || result.Ponder != "e7e5" || len(result.Lines) != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Lines[0].Depth != 4 || result.Lines[0].Score != 34 || result.Lines[1].PV[0] != "d2d4" {
+		t.Errorf("Unexpected lines %+v", result.Lines)
+	}
+	if len(infos) != 9 || infos[8].String != "hash 64" {
+		t.Errorf("Expected 9 info lines ending with the hash size, got %d", len(infos))
+	}
+
+	done := make(chan *SearchResult)
+	go func() {
+		r, _ := engine.Search("8/8/8/8/8/8/8/K6k w - - 0 1", nil, SearchParams{Infinite: true}, nil)
+		done <- r
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := engine.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case r := <-done:
+		if r == nil || r.BestMove != "e2e4" || r.Lines[0].Depth != 2 {
+			t.Errorf("Unexpected result after stop %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Search did not return after stop")
+	}
+
+	if err := engine.Quit(); err != nil {
+		t.Errorf("Unexpected error quitting: %v", err)
+	}
+	if _, err := engine.Search("", nil, SearchParams{Depth: 1}, nil); err != ErrEngineNotRunning {
+		t.Errorf("Expected ErrEngineNotRunning after quit, got %v", err)
+	}
+}
+
+func TestEngineCrashRecovery(t *testing.T) {
+	bin := buildFakeEngine(t)
+	marker := filepath.Join(t.TempDir(), "crashed")
+	engine := NewEngine(bin, "-crash-once", marker)
+	engine.Timeout = 5 * time.Second
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer engine.Quit()
+	engine.SetOption("Hash", "128")
+
+	var hash string
+	result, err := engine.Analyze("", nil, SearchParams{Depth: 2}, func(info EngineInfo) {
+		if info.String != "" {
+			hash = info.String
+		}
+	})
+	if err != nil {
+		t.Fatalf("Expected the engine to be restarted, got %v", err)
+	}
+	if result.BestMove != "e2e4" || hash != "hash 128" {
+		t.Errorf("Expected options to be restored after the crash, got %+v %q", result, hash)
+	}
+}
+
+func TestEngineTimeout(t *testing.T) {
+	engine := NewEngine(buildFakeEngine(t), "-hang")
+	engine.Timeout = 200 * time.Millisecond
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer engine.Quit()
+
+	start := time.Now()
+	_, err := engine.Search("", nil, SearchParams{MoveTime: 50 * time.Millisecond}, nil)
+	if err != ErrEngineTimeout {
+		t.Errorf("Expected ErrEngineTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Timeout took too long: %v", elapsed)
+	}
+
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	engine.SearchTimeout = 100 * time.Millisecond
+	start = time.Now()
+	_, err = engine.Search("", nil, SearchParams{Depth: 20}, nil)
+	if err != ErrEngineTimeout {
+		t.Errorf("Expected ErrEngineTimeout for a depth search, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Depth search timeout took too long: %v", elapsed)
+	}
+	if _, err := engine.Search("", nil, SearchParams{Depth: 20}, nil); err != ErrEngineNotRunning {
+		t.Errorf("Expected the hung engine to be killed, got %v", err)
+	}
+
+	if err := NewEngine(filepath.Join(t.TempDir(), "missing")).Start(); err == nil {
+		t.Errorf("Expected an error starting a missing engine")
+	}
+}
+
+func TestEngineQuitDuringInfiniteSearch(t *testing.T) {
+	bin := buildFakeEngine(t)
+	for _, args := range [][]string{nil, {"-hang"}} {
+		engine := NewEngine(bin, args...)
+		engine.Timeout = 200 * time.Millisecond
+		if err := engine.Start(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		searched := make(chan error, 1)
+		go func() {
+			_, err := engine.Search("", nil, SearchParams{Infinite: true}, nil)
+			searched <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		quit := make(chan error, 1)
+		go func() { quit <- engine.Quit() }()
+		select {
+		case <-quit:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: Quit blocked during an infinite search", args)
+		}
+		select {
+		case <-searched:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: the search did not end after Quit", args)
+		}
+	}
+}
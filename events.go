@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventChallenge         = "challenge"
+	EventChallengeCanceled = "challengeCanceled"
+	EventChallengeDeclined = "challengeDeclined"
+	EventGameStart         = "gameStart"
+	EventGameFinish        = "gameFinish"
+)
+
+var (
+	// ErrStreamDone can be returned by a stream handler to close the stream
+	// without an error.
+	ErrStreamDone = errors.New("stream done")
+	ErrStreamIdle = errors.New("stream received no data in time")
+)
+
+type EventVariant struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Short string `json:"short,omitempty"`
+}
+
+type Compat struct {
+	Bot   bool `json:"bot"`
+	Board bool `json:"board"`
+}
+
+type ChallengeUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Rating      int    `json:"rating"`
+	Title       string `json:"title,omitempty"`
+	Provisional bool   `json:"provisional,omitempty"`
+	Online      bool   `json:"online,omitempty"`
+	Lag         int    `json:"lag,omitempty"`
+}
+
+type ChallengeTimeControl struct {
+	Type        string `json:"type"`
+	Limit       int    `json:"limit,omitempty"`
+	Increment   int    `json:"increment,omitempty"`
+	Show        string `json:"show,omitempty"`
+	DaysPerTurn int    `json:"daysPerTurn,omitempty"`
+}
+
+type ChallengePerf struct {
+	Icon string `json:"icon"`
+	Name string `json:"name"`
+}
+
+type Challenge struct {
+	ID               string               `json:"id"`
+	URL              string               `json:"url"`
+	Status           string               `json:"status"`
+	Challenger       *ChallengeUser       `json:"challenger"`
+	DestUser         *ChallengeUser       `json:"destUser"`
+	Variant          EventVariant         `json:"variant"`
+	Rated            bool                 `json:"rated"`
+	Speed            string               `json:"speed"`
+	TimeControl      ChallengeTimeControl `json:"timeControl"`
+	Color            string               `json:"color"`
+	FinalColor       string               `json:"finalColor,omitempty"`
+	Perf             ChallengePerf        `json:"perf"`
+	Direction        string               `json:"direction,omitempty"`
+	InitialFen       string               `json:"initialFen,omitempty"`
+	Rules            []string             `json:"rules,omitempty"`
+	DeclineReason    string               `json:"declineReason,omitempty"`
+	DeclineReasonKey string               `json:"declineReasonKey,omitempty"`
+}
+
+type GameEventOpponent struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+	AiLevel  int    `json:"ai,omitempty"`
+}
+
+type GameEventStatus struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type GameEventInfo struct {
+	ID          string            `json:"id"`
+	GameID      string            `json:"gameId"`
+	FullID      string            `json:"fullId"`
+	Color       string            `json:"color"`
+	Fen         string            `json:"fen"`
+	HasMoved    bool              `json:"hasMoved"`
+	IsMyTurn    bool              `json:"isMyTurn"`
+	LastMove    string            `json:"lastMove"`
+	Opponent    GameEventOpponent `json:"opponent"`
+	Perf        string            `json:"perf"`
+	Rated       bool              `json:"rated"`
+	SecondsLeft int               `json:"secondsLeft"`
+	Source      string            `json:"source"`
+	Status      GameEventStatus   `json:"status"`
+	Speed       string            `json:"speed"`
+	Variant     EventVariant      `json:"variant"`
+	Winner      string            `json:"winner,omitempty"`
+	RatingDiff  int               `json:"ratingDiff,omitempty"`
+	Compat      Compat            `json:"compat"`
+}
+
+// Event is a message of the incoming event stream. Challenge is set for
+// the challenge events and Game for gameStart and gameFinish.
+type Event struct {
+	Type      string         `json:"type"`
+	Challenge *Challenge     `json:"challenge,omitempty"`
+	Game      *GameEventInfo `json:"game,omitempty"`
+	Compat    *Compat        `json:"compat,omitempty"`
+}
+
+type StreamOptions struct {
+	// Stop closes the stream when it is closed.
+	Stop <-chan struct{}
+	// Idle drops the connection when nothing, not even a keep-alive
+	// newline, arrived for that long. Zero disables the check.
+	Idle time.Duration
+	// Reconnect opens the stream again when it ends or fails, waiting
+	// between MinBackoff and MaxBackoff, but never less than
+	// minStreamBackoff.
+	Reconnect  bool
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnError is called with each error that causes a reconnect and each
+	// line that is skipped because it could not be decoded.
+	OnError func(error)
+}
+
+func NewStreamOptions() StreamOptions {
+	return StreamOptions{
+		Idle:       20 * time.Second,
+		Reconnect:  true,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// rateLimitBackoff is how long Lichess asks clients to wait after a 429.
+var rateLimitBackoff = time.Minute
+
+// minStreamBackoff keeps a stream that ends right away from reconnecting
+// in a busy loop.
+var minStreamBackoff = 100 * time.Millisecond
+
+// StreamLineError is reported for a line of a stream that could not be
+// decoded. The line is skipped and the stream goes on.
+type StreamLineError struct {
+	Line string
+	Err  error
+}
+
+func (e *StreamLineError) Error() string {
+	return fmt.Sprintf("skipping stream line %q: %v", e.Line, e.Err)
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// stream reads an ndjson endpoint and passes each line to fn, skipping the
+// empty keep-alive lines. Errors from fn end the stream, except for
+// StreamLineErrors which only skip the line; ErrStreamDone ends it without
+// error.
+func (c *Client) stream(endPoint string, opts StreamOptions, fn func([]byte) error) error {
+	minBackoff, maxBackoff := opts.MinBackoff, opts.MaxBackoff
+	if minBackoff < minStreamBackoff {
+		minBackoff = minStreamBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	backoff := minBackoff
+	for {
+		received, handlerErr, err := c.streamOnce(endPoint, opts, fn)
+		switch {
+		case handlerErr == ErrStreamDone:
+			return nil
+		case handlerErr != nil:
+			return handlerErr
+		case stopped(opts.Stop):
+			return nil
+		case !opts.Reconnect:
+			return err
+		}
+
+		wait := backoff
+		if apiErr, ok := err.(*APIError); ok {
+			if apiErr.StatusCode == http.StatusTooManyRequests {
+				wait = rateLimitBackoff
+			} else if apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+				return err
+			}
+		}
+		if err != nil && opts.OnError != nil {
+			opts.OnError(err)
+		}
+
+		if received {
+			backoff = minBackoff
+		} else if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		select {
+		case <-opts.Stop:
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) streamOnce(endPoint string, opts StreamOptions, fn func([]byte) error) (received bool, handlerErr error, err error) {
+	params := c.DefaultRequestParams()
+	params.Accept = "application/x-ndjson"
+	req, err := c.NewRequest(c.baseURL()+endPoint, params)
+	if err != nil {
+		return false, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	go func() {
+		select {
+		case <-opts.Stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var idle int32
+	touch := func() {}
+	if opts.Idle > 0 {
+		timer := time.AfterFunc(opts.Idle, func() {
+			atomic.StoreInt32(&idle, 1)
+			cancel()
+		})
+		defer timer.Stop()
+		touch = func() { timer.Reset(opts.Idle) }
+	}
+	idleErr := func(err error) error {
+		if atomic.LoadInt32(&idle) == 1 {
+			return ErrStreamIdle
+		}
+		return err
+	}
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return false, nil, idleErr(err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return false, nil, err
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			touch()
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			received = true
+			handlerErr := fn(line)
+			if lineErr, ok := handlerErr.(*StreamLineError); ok {
+				if opts.OnError != nil {
+					opts.OnError(lineErr)
+				}
+			} else if handlerErr != nil {
+				return received, handlerErr, nil
+			}
+		}
+		if err == io.EOF {
+			return received, nil, nil
+		}
+		if err != nil {
+			return received, nil, idleErr(err)
+		}
+	}
+}
+
+// StreamEvents follows the incoming events of the account: challenges sent
+// and received and the start and end of its games.
+func (c *Client) StreamEvents(opts StreamOptions, fn func(*Event) error) error {
+	return c.stream("/api/stream/event", opts, func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return &StreamLineError{Line: string(line), Err: err}
+		}
+		return fn(&event)
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const challengeEvent = `{"type":"challenge","challenge":{"id":"7pGLxJ4F","url":"https://lichess.org/7pGLxJ4F","status":"created","challenger":{"id":"bobby","name":"Bobby","rating":1635,"title":"BOT","online":true},"destUser":{"id":"alice","name":"Alice","rating":1500,"provisional":true},"variant":{"key":"standard","name":"Standard","short":"Std"},"rated":true,"speed":"rapid","timeControl":{"type":"clock","limit":600,"increment":5,"show":"10+5"},"color":"random","finalColor":"black","perf":{"icon":"#","name":"Rapid"},"direction":"in"},"compat":{"bot":true,"board":true}}`
+
+const gameStartEvent = `{"type":"gameStart","game":{"gameId":"rCRw1AuO","fullId":"rCRw1AuOvonq","color":"black","fen":"r1bqkbnr/pppp2pp/2n1pp2/8/8/3PP3/PPPKBPPP/RNBQ2NR w kq - 0 1","hasMoved":true,"isMyTurn":false,"lastMove":"b8c6","opponent":{"id":"philippe","username":"Philippe","rating":1790},"perf":"correspondence","rated":false,"secondsLeft":1209600,"source":"friend","status":{"id":20,"name":"started"},"speed":"correspondence","variant":{"key":"standard","name":"Standard"},"compat":{"bot":false,"board":true},"id":"rCRw1AuO"}}`
+
+const gameFinishEvent = `{"type":"gameFinish","game":{"gameId":"rCRw1AuO","fullId":"rCRw1AuOvonq","color":"black","opponent":{"id":"philippe","username":"Philippe","rating":1790},"status":{"id":31,"name":"resign"},"winner":"black","ratingDiff":8}}`
+
+func TestStreamEvents(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/stream/event" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, `{"error":"No such token"}`, http.StatusUnauthorized)
+			return
+		}
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			fmt.Fprint(w, "\n{not json\n"+challengeEvent+"\n\n")
+		case 2:
+			http.Error(w, "try again", http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "\n")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, gameStartEvent+"\n"+gameFinishEvent+"\n")
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{Token: "secret", HttpClient: server.Client(), BaseURL: server.URL}
+	opts := NewStreamOptions()
+	opts.MinBackoff = time.Millisecond
+	var errs []error
+	opts.OnError = func(err error) { errs = append(errs, err) }
+
+	var events []*Event
+	err := c.StreamEvents(opts, func(e *Event) error {
+		events = append(events, e)
+		if e.Type == EventGameFinish {
+			return ErrStreamDone
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 3 || connections != 3 {
+		t.Fatalf("Expected 3 events over 3 connections, got %d over %d", len(events), connections)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected the bad line and the bad gateway to be reported, got %v", errs)
+	}
+	if lineErr, ok := errs[0].(*StreamLineError); !ok || lineErr.Line != "{not json" {
+		t.Errorf("Expected the bad line to be skipped, got %v", errs[0])
+	}
+	if apiErr, ok := errs[1].(*APIError); !ok || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the bad gateway to be reported, got %v", errs[1])
+	}
+
+	ch := events[0].Challenge
+	if events[0].Type != EventChallenge || ch == nil || ch.Challenger.Title != "BOT" || !ch.DestUser.Provisional {
+		t.Errorf("Unexpected challenge event %+v", events[0])
+	}
+	if ch.TimeControl.Limit != 600 || ch.TimeControl.Increment != 5 || ch.Variant.Key != VariantStandard || !events[0].Compat.Bot {
+		t.Errorf("Unexpected challenge %+v", ch)
+	}
+	game := events[1].Game
+	if events[1].Type != EventGameStart || game.FullID != "rCRw1AuOvonq" || game.Opponent.Rating != 1790 || !game.Compat.Board {
+		t.Errorf("Unexpected game start %+v", events[1])
+	}
+	if game := events[2].Game; game.Winner != "black" || game.Status.Name != "resign" || game.RatingDiff != 8 {
+		t.Errorf("Unexpected game finish %+v", events[2])
+	}
+
+	c.Token = "wrong"
+	err = c.StreamEvents(opts, func(*Event) error { return nil })
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "No such token" {
+		t.Errorf("Expected an unauthorized error without retrying, got %v", err)
+	}
+}
+
+func TestStreamEventsIdleAndStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := &Client{HttpClient: server.Client(), BaseURL: server.URL}
+	opts := StreamOptions{Idle: 50 * time.Millisecond}
+	if err := c.StreamEvents(opts, func(*Event) error { return nil }); err != ErrStreamIdle {
+		t.Errorf("Expected ErrStreamIdle, got %v", err)
+	}
+
+	stop := make(chan struct{})
+	opts = NewStreamOptions()
+	opts.Stop = stop
+	done := make(chan error)
+	go func() {
+		done <- c.StreamEvents(opts, func(*Event) error { return nil })
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error after stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stream did not stop")
+	}
+}
+
+func TestStreamMinBackoff(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+	}))
+	defer server.Close()
+
+	c := &Client{HttpClient: server.Client(), BaseURL: server.URL}
+	opts := NewStreamOptions()
+	opts.MinBackoff, opts.MaxBackoff = 0, 0
+	stop := make(chan struct{})
+	opts.Stop = stop
+	time.AfterFunc(350*time.Millisecond, func() { close(stop) })
+	if err := c.StreamEvents(opts, func(*Event) error { return nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&connections); n > 5 {
+		t.Errorf("Expected the reconnects to back off, got %d connections", n)
+	}
+}
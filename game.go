@@ -1,5 +1,10 @@
 package main
 
+import (
+	"net/url"
+	"strconv"
+)
+
 type GameParam struct {
 	Moves     bool
 	PgnInJson bool
@@ -24,6 +29,79 @@ func NewGameParam() GameParam {
 	}
 }
 
+type UserGamesParam struct {
+	Since     int64
+	Until     int64
+	Max       int
+	Rated     string
+	PerfType  string
+	Clocks    bool
+	Evals     bool
+	Opening   bool
+	Ascending bool
+}
+
+func NewUserGamesParam() UserGamesParam {
+	return UserGamesParam{
+		Clocks:  true,
+		Evals:   true,
+		Opening: true,
+	}
+}
+
+func (p UserGamesParam) Values() url.Values {
+	values := url.Values{}
+	if p.Since > 0 {
+		values.Set("since", strconv.FormatInt(p.Since, 10))
+	}
+	if p.Until > 0 {
+		values.Set("until", strconv.FormatInt(p.Until, 10))
+	}
+	if p.Max > 0 {
+		values.Set("max", strconv.Itoa(p.Max))
+	}
+	if p.Rated != "" {
+		values.Set("rated", p.Rated)
+	}
+	if p.PerfType != "" {
+		values.Set("perfType", p.PerfType)
+	}
+	values.Set("clocks", strconv.FormatBool(p.Clocks))
+	values.Set("evals", strconv.FormatBool(p.Evals))
+	values.Set("opening", strconv.FormatBool(p.Opening))
+	if p.Ascending {
+		values.Set("sort", "dateAsc")
+	}
+	return values
+}
+
+type GameUser struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Patron bool   `json:"patron"`
+	ID     string `json:"id"`
+}
+
+type GamePlayer struct {
+	User       GameUser `json:"user"`
+	Rating     int      `json:"rating"`
+	RatingDiff int      `json:"ratingDiff"`
+	AiLevel    int      `json:"aiLevel"`
+}
+
+type Judgment struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+}
+
+type MoveAnalysis struct {
+	Eval      int       `json:"eval"`
+	Mate      int       `json:"mate"`
+	Best      string    `json:"best,omitempty"`
+	Variation string    `json:"variation,omitempty"`
+	Judgment  *Judgment `json:"judgment,omitempty"`
+}
+
 type Game struct {
 	ID         string `json:"id"`
 	Rated      bool   `json:"rated"`
@@ -33,35 +111,20 @@ type Game struct {
 	CreatedAt  int64  `json:"createdAt"`
 	LastMoveAt int64  `json:"lastMoveAt"`
 	Status     string `json:"status"`
+	Winner     string `json:"winner"`
+	InitialFen string `json:"initialFen"`
 	Players    struct {
-		White struct {
-			User struct {
-				Name   string `json:"name"`
-				Title  string `json:"title"`
-				Patron bool   `json:"patron"`
-				ID     string `json:"id"`
-			} `json:"user"`
-			Rating     int `json:"rating"`
-			RatingDiff int `json:"ratingDiff"`
-		} `json:"white"`
-		Black struct {
-			User struct {
-				Name string `json:"name"`
-				ID   string `json:"id"`
-			} `json:"user"`
-			Rating     int `json:"rating"`
-			RatingDiff int `json:"ratingDiff"`
-		} `json:"black"`
+		White GamePlayer `json:"white"`
+		Black GamePlayer `json:"black"`
 	} `json:"players"`
-	Opening struct {
-		Eco  string `json:"eco"`
-		Name string `json:"name"`
-		Ply  int    `json:"ply"`
-	} `json:"opening"`
-	Moves string `json:"moves"`
-	Clock struct {
+	Opening Opening `json:"opening"`
+	Moves   string  `json:"moves"`
+	Clock   struct {
 		Initial   int `json:"initial"`
 		Increment int `json:"increment"`
 		TotalTime int `json:"totalTime"`
 	} `json:"clock"`
+	DaysPerTurn int            `json:"daysPerTurn"`
+	Clocks      []int          `json:"clocks"`
+	Analysis    []MoveAnalysis `json:"analysis"`
 }
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const usage = `usage: lichess <command> [flags]
+
+commands:
+  sync    download new games of users into the local archive
+  search  search the local archive
+  analyze annotate archived games with a UCI engine
+  bot     play as a bot account with a UCI engine
+  board   print a position given as FEN
+  replay  step through a game fetched from Lichess
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "sync":
+		err = runSync(os.Args[2:])
+	case "search":
+		err = runSearch(os.Args[2:])
+	case "analyze":
+		err = runAnalyze(os.Args[2:])
+	case "bot":
+		err = runBot(os.Args[2:])
+	case "board":
+		err = runBoard(os.Args[2:])
+	case "replay":
+		err = runReplay(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newCommandClient() *Client {
+	return &Client{
+		Token:      os.Getenv("LICHESS_TOKEN"),
+		HttpClient: &http.Client{},
+	}
+}
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("dir", "archive", "archive directory")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: lichess sync [-dir dir] user...")
+	}
+
+	archive, err := NewArchive(*dir)
+	if err != nil {
+		return err
+	}
+	client := newCommandClient()
+	for _, user := range fs.Args() {
+		added, err := archive.Sync(client, user, NewUserGamesParam())
+		if err != nil {
+			return fmt.Errorf("%s: %v", user, err)
+		}
+		fmt.Printf("%s: %d new games\n", user, added)
+	}
+	return nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dir := fs.String("dir", "archive", "archive directory")
+	user := fs.String("user", "", "only search the games of this user")
+	pgn := fs.Bool("pgn", false, "print matching games as PGN")
+	limit := fs.Int("n", 0, "maximum number of games to print")
+	fs.Parse(args)
+
+	query, err := ParseQuery(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	archive := &Archive{Dir: *dir}
+	users := []string{*user}
+	if *user == "" {
+		if users, err = archive.Users(); err != nil {
+			return err
+		}
+	} else if query.Player == "" {
+		query.Player = *user
+	}
+
+	seen := make(map[string]bool)
+	games := make([]Game, 0)
+	for _, u := range users {
+		err := archive.Each(u, func(game *Game) error {
+			if !seen[game.ID] {
+				seen[game.ID] = true
+				games = append(games, *game)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, game := range NewGameIndex(games).Search(query) {
+		if *limit > 0 && i >= *limit {
+			break
+		}
+		if *pgn {
+			fmt.Println(NewPgnGame(&game).String())
+			continue
+		}
+		fmt.Println(formatGameLine(&game))
+	}
+	return nil
+}
+
+func formatGameLine(game *Game) string {
+	created := time.Unix(0, game.CreatedAt*int64(time.Millisecond)).UTC()
+	return fmt.Sprintf("%s  %s (%d) - %s (%d)  %s  %s %s  %s  %s/%s",
+		created.Format(queryDateLayout),
+		gamePlayerName(game.Players.White), game.Players.White.Rating,
+		gamePlayerName(game.Players.Black), game.Players.Black.Rating,
+		GameResult(game), game.Opening.Eco, game.Opening.Name, game.Speed,
+		LichessBase, game.ID)
+}
+
+func runAnalyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	dir := fs.String("dir", "archive", "archive directory")
+	engine := fs.String("engine", "stockfish", "path to a UCI engine")
+	engines := fs.Int("engines", 2, "number of engines to run in parallel")
+	depth := fs.Int("depth", NewAnalysisOptions().Depth, "search depth per position")
+	movetime := fs.Duration("movetime", 0, "search time per position instead of a depth")
+	out := fs.String("out", "", "annotated PGN file, appended to (default user.pgn)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lichess analyze [flags] user")
+	}
+	user := fs.Arg(0)
+	if *out == "" {
+		*out = strings.ToLower(user) + ".pgn"
+	}
+
+	games, err := (&Archive{Dir: *dir}).Games(user)
+	if err != nil {
+		return err
+	}
+	progress, err := LoadAnalysisProgress(*out + ".progress")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pool, err := NewEnginePool(*engines, *engine)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	options := NewAnalysisOptions()
+	options.Depth = *depth
+	if *movetime > 0 {
+		options.Depth = 0
+		options.MoveTime = *movetime
+	}
+	pipeline := &AnalysisPipeline{
+		Pool:     pool,
+		Options:  options,
+		Progress: progress,
+		OnGame: func(game *Game) {
+			fmt.Fprintf(os.Stderr, "%d/%d %s\n", progress.Len(), len(games), game.ID)
+		},
+	}
+	written, err := pipeline.Run(games, f)
+	fmt.Printf("%s: %d games analyzed\n", user, written)
+	return err
+}
+
+func runBot(args []string) error {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	engine := fs.String("engine", "stockfish", "path to a UCI engine")
+	games := fs.Int("games", NewBotOptions().MaxGames, "maximum number of games played at once")
+	policyPath := fs.String("policy", "", "JSON file with the challenges to accept")
+	matchmaking := fs.Bool("matchmaking", false, "challenge other online bots while idle")
+	rated := fs.Bool("rated", false, "make the challenges of the matchmaking rated")
+	limit := fs.Duration("limit", NewMatchmakerOptions().Challenge.Limit, "clock of the matchmaking challenges")
+	increment := fs.Duration("increment", NewMatchmakerOptions().Challenge.Increment, "increment of the matchmaking challenges")
+	fs.Parse(args)
+
+	policy := NewChallengePolicy()
+	if *policyPath != "" {
+		loaded, err := LoadChallengePolicy(*policyPath)
+		if err != nil {
+			return err
+		}
+		policy = *loaded
+	}
+
+	pool, err := NewEnginePool(*games, *engine)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	client := newCommandClient()
+	bot := NewBotRunner(client, &EngineMoveChooser{Pool: pool})
+	bot.Options.MaxGames = *games
+	bot.Policy = &policy
+	bot.Options.Logf = log.Printf
+	bot.Options.Stream.OnError = func(err error) { log.Printf("stream: %v", err) }
+
+	if *matchmaking {
+		account, err := client.GetAccount()
+		if err != nil {
+			return err
+		}
+		options := NewMatchmakerOptions()
+		options.Challenge.Rated = *rated
+		options.Challenge.Limit = *limit
+		options.Challenge.Increment = *increment
+		matchmaker := NewMatchmaker(client, account.ID, options)
+		matchmaker.Logf = log.Printf
+		matchmaker.Idle = func() bool { return bot.Playing() == 0 }
+		bot.OnEvent = matchmaker.HandleEvent
+		stop := make(chan struct{})
+		defer close(stop)
+		go matchmaker.Run(stop)
+	}
+
+	// The first interrupt lets the games in progress finish, the second
+	// one leaves them.
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		log.Printf("shutting down after %d games in progress", bot.Playing())
+		bot.Shutdown()
+		<-interrupts
+		bot.Stop()
+	}()
+	return bot.Run()
+}
+
+func terminalFlags(fs *flag.FlagSet) func() TerminalOptions {
+	ascii := fs.Bool("ascii", false, "use ASCII letters instead of Unicode pieces")
+	plain := fs.Bool("plain", false, "disable ANSI colors")
+	flip := fs.Bool("flip", false, "show the board from black's side")
+	return func() TerminalOptions {
+		opts := NewTerminalOptions()
+		opts.Unicode = !*ascii
+		opts.Colors = !*plain
+		opts.Flipped = *flip
+		return opts
+	}
+}
+
+func runBoard(args []string) error {
+	fs := flag.NewFlagSet("board", flag.ExitOnError)
+	variant := fs.String("variant", VariantStandard, "variant of the position")
+	options := terminalFlags(fs)
+	fs.Parse(args)
+
+	fen := strings.Join(fs.Args(), " ")
+	if fen == "" {
+		fen, _ = VariantStartingFen(*variant)
+	}
+	pos, err := ParseVariantFen(*variant, fen)
+	if err != nil {
+		return err
+	}
+	fmt.Print(RenderTerminal(pos, options()))
+	return nil
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	options := terminalFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: lichess replay [flags] gameId")
+	}
+
+	game, err := newCommandClient().GetGame(fs.Arg(0), NewGameParam())
+	if err != nil {
+		return err
+	}
+	replayed, err := ReplayGame(game)
+	if err != nil {
+		return err
+	}
+	return StepThrough(os.Stdin, os.Stdout, replayed, options())
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoOpponent = errors.New("no online bot to challenge")
+
+type MatchmakerOptions struct {
+	Challenge ChallengeParams
+	// MinRating and MaxRating bound the opponent's rating in the perf of
+	// the challenge. Zero limits are not checked.
+	MinRating int
+	MaxRating int
+	// Timeout cancels challenges left unanswered for that long.
+	Timeout time.Duration
+	// Cooldown is how long to wait before challenging an opponent again.
+	Cooldown time.Duration
+	// Interval is how often Run looks for a game while idle.
+	Interval time.Duration
+	// OnlineBots is how many online bots are listed per attempt.
+	OnlineBots int
+	Blocklist  []string
+}
+
+func NewMatchmakerOptions() MatchmakerOptions {
+	return MatchmakerOptions{
+		Challenge:  ChallengeParams{Limit: 3 * time.Minute, Increment: 2 * time.Second, Variant: VariantStandard},
+		Timeout:    time.Minute,
+		Cooldown:   time.Hour,
+		Interval:   30 * time.Second,
+		OnlineBots: 100,
+	}
+}
+
+type pendingChallenge struct {
+	Opponent string
+	Created  time.Time
+}
+
+// Matchmaker challenges online bots while the bot is idle. It keeps at most
+// one challenge pending at a time and does not challenge the same opponent
+// again within the cooldown.
+type Matchmaker struct {
+	Client  *Client
+	Options MatchmakerOptions
+	// Username is the bot itself, which is never challenged.
+	Username string
+	// Idle reports whether the bot has room for another game.
+	Idle func() bool
+	Logf func(format string, args ...interface{})
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	now        func() time.Time
+	pending    map[string]pendingChallenge
+	challenged map[string]time.Time
+}
+
+func NewMatchmaker(client *Client, username string, options MatchmakerOptions) *Matchmaker {
+	return &Matchmaker{
+		Client:     client,
+		Options:    options,
+		Username:   username,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:        time.Now,
+		pending:    make(map[string]pendingChallenge),
+		challenged: make(map[string]time.Time),
+	}
+}
+
+func (m *Matchmaker) logf(format string, args ...interface{}) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
+
+// Pending returns the number of challenges waiting for an answer.
+func (m *Matchmaker) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pending)
+}
+
+// Candidates lists the online bots that may be challenged now. For a
+// variant challenge only bots that have played the variant are listed.
+func (m *Matchmaker) Candidates() ([]Account, error) {
+	bots, err := m.Client.GetOnlineBots(m.Options.OnlineBots)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	busy := make(map[string]bool)
+	for _, p := range m.pending {
+		busy[p.Opponent] = true
+	}
+	perf := m.Options.Challenge.Perf()
+	variant := perf == m.Options.Challenge.Variant
+	candidates := make([]Account, 0, len(bots))
+	for _, bot := range bots {
+		id := strings.ToLower(bot.ID)
+		if strings.EqualFold(id, m.Username) || busy[id] || containsFold(m.Options.Blocklist, id) {
+			continue
+		}
+		if last, ok := m.challenged[id]; ok && m.now().Sub(last) < m.Options.Cooldown {
+			continue
+		}
+		rating, played := bot.Perfs.Rating(perf)
+		if !played && (variant || m.Options.MinRating > 0 || m.Options.MaxRating > 0) {
+			continue
+		}
+		if low, high := outOfRange(rating, m.Options.MinRating, m.Options.MaxRating); low || high {
+			continue
+		}
+		candidates = append(candidates, bot)
+	}
+	return candidates, nil
+}
+
+// ChallengeOne challenges a random candidate.
+func (m *Matchmaker) ChallengeOne() (*Challenge, error) {
+	candidates, err := m.Candidates()
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoOpponent
+	}
+
+	m.mu.Lock()
+	opponent := candidates[m.rand.Intn(len(candidates))]
+	id := strings.ToLower(opponent.ID)
+	m.challenged[id] = m.now()
+	m.mu.Unlock()
+
+	challenge, err := m.Client.CreateChallenge(opponent.Username, m.Options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.pending[challenge.ID] = pendingChallenge{Opponent: id, Created: m.now()}
+	m.mu.Unlock()
+	m.logf("challenged %s (%s)", opponent.Username, challenge.ID)
+	return challenge, nil
+}
+
+// ExpirePending cancels the challenges that were not answered in time.
+func (m *Matchmaker) ExpirePending() {
+	m.cancel(func(p pendingChallenge) bool {
+		return m.now().Sub(p.Created) >= m.Options.Timeout
+	})
+}
+
+func (m *Matchmaker) cancel(match func(pendingChallenge) bool) {
+	m.mu.Lock()
+	var expired []string
+	for id, p := range m.pending {
+		if match(p) {
+			expired = append(expired, id)
+			delete(m.pending, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range expired {
+		if err := m.Client.CancelChallenge(id, ""); err != nil {
+			m.logf("canceling challenge %s: %v", id, err)
+		}
+	}
+}
+
+// HandleEvent forgets pending challenges once they are answered. It is
+// meant to receive the events of the bot's event stream.
+func (m *Matchmaker) HandleEvent(event *Event) {
+	id := ""
+	switch {
+	case (event.Type == EventChallengeDeclined || event.Type == EventChallengeCanceled) && event.Challenge != nil:
+		id = event.Challenge.ID
+	case event.Type == EventGameStart && event.Game != nil:
+		id = event.Game.GameID
+		if id == "" {
+			id = event.Game.ID
+		}
+	}
+	m.mu.Lock()
+	delete(m.pending, id)
+	m.mu.Unlock()
+}
+
+// Step expires old challenges and, when the bot is idle and no challenge
+// is pending, challenges a new opponent.
+func (m *Matchmaker) Step() {
+	m.ExpirePending()
+	if m.Pending() > 0 || (m.Idle != nil && !m.Idle()) {
+		return
+	}
+	if _, err := m.ChallengeOne(); err != nil && err != ErrNoOpponent {
+		m.logf("matchmaking: %v", err)
+	}
+}
+
+// Run calls Step every interval until stop is closed, then cancels the
+// pending challenges.
+func (m *Matchmaker) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.Options.Interval)
+	defer ticker.Stop()
+	for {
+		m.Step()
+		select {
+		case <-stop:
+			m.cancel(func(pendingChallenge) bool { return true })
+			return
+		case <-ticker.C:
+		}
+	}
+}
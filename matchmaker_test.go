@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchmaker(t *testing.T) {
+	var mu sync.Mutex
+	var challenges, cancels []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/bot/online":
+			for _, bot := range []struct {
+				name   string
+				rating int
+				games  int
+			}{{"MyBot", 1800, 10}, {"Weak", 1000, 50}, {"Strong", 2000, 80}, {"Fresh", 0, 0}, {"Blocked", 1900, 5}, {"Other", 2100, 30}} {
+				fmt.Fprintf(w, `{"id":"%s","username":"%s","title":"BOT","perfs":{"blitz":{"games":%d,"rating":%d}}}`+"\n", strings.ToLower(bot.name), bot.name, bot.games, bot.rating)
+			}
+		case strings.HasSuffix(r.URL.Path, "/cancel"):
+			cancels = append(cancels, strings.Split(r.URL.Path, "/")[3])
+			fmt.Fprint(w, `{"ok":true}`)
+		case strings.HasPrefix(r.URL.Path, "/api/challenge/"):
+			body, _ := io.ReadAll(r.Body)
+			user := strings.TrimPrefix(r.URL.Path, "/api/challenge/")
+			challenges = append(challenges, user+" "+string(body))
+			fmt.Fprintf(w, `{"challenge":{"id":"c-%s","status":"created","destUser":{"id":"%s"}}}`, strings.ToLower(user), strings.ToLower(user))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	options := NewMatchmakerOptions()
+	options.MinRating = 1500
+	options.MaxRating = 2500
+	options.Blocklist = []string{"blocked"}
+	m := NewMatchmaker(&Client{HttpClient: server.Client(), BaseURL: server.URL}, "mybot", options)
+	now := time.Unix(1000000, 0)
+	m.now = func() time.Time { return now }
+	idle := true
+	m.Idle = func() bool { return idle }
+
+	candidates, err := m.Candidates()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Username)
+	}
+	if strings.Join(names, ",") != "Strong,Other" {
+		t.Fatalf("Unexpected candidates %v", names)
+	}
+
+	m.Step()
+	m.Step()
+	if len(challenges) != 1 || m.Pending() != 1 {
+		t.Fatalf("Expected a single pending challenge, got %v", challenges)
+	}
+	if !strings.HasSuffix(challenges[0], "clock.increment=2&clock.limit=180&rated=false&variant=standard") {
+		t.Errorf("Unexpected challenge %q", challenges[0])
+	}
+	first := strings.Fields(challenges[0])[0]
+
+	now = now.Add(options.Timeout)
+	m.Step()
+	if len(cancels) != 1 || cancels[0] != "c-"+strings.ToLower(first) {
+		t.Errorf("Expected the unanswered challenge to be canceled, got %v", cancels)
+	}
+	if len(challenges) != 2 || strings.HasPrefix(challenges[1], first) {
+		t.Fatalf("Expected the other bot to be challenged next, got %v", challenges)
+	}
+
+	second := strings.ToLower(strings.Fields(challenges[1])[0])
+	m.HandleEvent(&Event{Type: EventChallengeDeclined, Challenge: &Challenge{ID: "c-" + second}})
+	if m.Pending() != 0 {
+		t.Errorf("Expected the declined challenge to be forgotten")
+	}
+	if _, err := m.ChallengeOne(); err != ErrNoOpponent {
+		t.Errorf("Expected both bots to be cooling down, got %v", err)
+	}
+
+	idle = false
+	now = now.Add(options.Cooldown)
+	m.Step()
+	if len(challenges) != 2 {
+		t.Errorf("Expected no challenge while busy")
+	}
+	idle = true
+	m.Step()
+	if len(challenges) != 3 || m.Pending() != 1 {
+		t.Errorf("Expected a challenge after the cooldown, got %v", challenges)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	m.Run(stop)
+	if m.Pending() != 0 || len(cancels) != 2 {
+		t.Errorf("Expected pending challenges to be canceled when stopping, got %v", cancels)
+	}
+}
+
+func TestMatchmakerVariant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"id":"house","username":"House","title":"BOT","perfs":{"blitz":{"games":10,"rating":1500},"crazyhouse":{"games":20,"rating":1900}}}`)
+		fmt.Fprintln(w, `{"id":"housewife","username":"Housewife","title":"BOT","perfs":{"blitz":{"games":10,"rating":1500},"crazyhouse":{"games":5,"rating":1400}}}`)
+		fmt.Fprintln(w, `{"id":"plain","username":"Plain","title":"BOT","perfs":{"blitz":{"games":90,"rating":2000}}}`)
+	}))
+	defer server.Close()
+
+	options := NewMatchmakerOptions()
+	options.Challenge.Variant = VariantCrazyhouse
+	m := NewMatchmaker(&Client{HttpClient: server.Client(), BaseURL: server.URL}, "mybot", options)
+	names := func() string {
+		candidates, err := m.Candidates()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var names []string
+		for _, c := range candidates {
+			names = append(names, c.Username)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := names(); got != "House,Housewife" {
+		t.Errorf("Expected only bots that played crazyhouse, got %v", got)
+	}
+	m.Options.MinRating = 1500
+	if got := names(); got != "House" {
+		t.Errorf("Expected the crazyhouse rating to be checked, got %v", got)
+	}
+}
+
+func TestChallengeParams(t *testing.T) {
+	if perf := (ChallengeParams{Limit: time.Minute}).Perf(); perf != "bullet" {
+		t.Errorf("Unexpected perf %q", perf)
+	}
+	if perf := (ChallengeParams{Limit: 10 * time.Minute, Variant: VariantAtomic}).Perf(); perf != VariantAtomic {
+		t.Errorf("Unexpected perf %q", perf)
+	}
+	values := ChallengeParams{Days: 3, Color: "black", Fen: StartingFen}.Values()
+	if values.Get("days") != "3" || values.Get("clock.limit") != "" || values.Get("color") != "black" || values.Get("fen") != StartingFen {
+		t.Errorf("Unexpected values %v", values)
+	}
+}
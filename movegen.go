@@ -0,0 +1,483 @@
+package main
+
+type Move struct {
+	From      Square
+	To        Square
+	Promotion PieceType
+	Drop      PieceType
+	Castling  bool
+}
+
+var NullMove = Move{From: NoSquare, To: NoSquare}
+
+type direction struct {
+	df, dr int
+}
+
+var (
+	knightSteps  = []direction{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps    = []direction{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	bishopRays   = []direction{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+	rookRays     = []direction{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	promotionTos = []PieceType{Queen, Rook, Bishop, Knight}
+)
+
+func (sq Square) step(d direction) Square {
+	return NewSquare(sq.File()+d.df, sq.Rank()+d.dr)
+}
+
+func pawnDirection(c Color) int {
+	if c == White {
+		return 1
+	}
+	return -1
+}
+
+func (p *Position) IsAttacked(sq Square, by Color) bool {
+	return p.isAttacked(sq, by, true)
+}
+
+func (p *Position) isAttacked(sq Square, by Color, byKing bool) bool {
+	for _, d := range knightSteps {
+		if to := sq.step(d); to != NoSquare && p.Board[to] == (Piece{Type: Knight, Color: by}) {
+			return true
+		}
+	}
+	if byKing {
+		for _, d := range kingSteps {
+			if to := sq.step(d); to != NoSquare && p.Board[to] == (Piece{Type: King, Color: by}) {
+				return true
+			}
+		}
+	}
+	for _, df := range []int{-1, 1} {
+		from := sq.step(direction{df, -pawnDirection(by)})
+		if from != NoSquare && p.Board[from] == (Piece{Type: Pawn, Color: by}) {
+			return true
+		}
+	}
+	for _, d := range bishopRays {
+		if pc := p.firstPiece(sq, d); pc.Color == by && (pc.Type == Bishop || pc.Type == Queen) {
+			return true
+		}
+	}
+	for _, d := range rookRays {
+		if pc := p.firstPiece(sq, d); pc.Color == by && (pc.Type == Rook || pc.Type == Queen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Position) firstPiece(sq Square, d direction) Piece {
+	for to := sq.step(d); to != NoSquare; to = to.step(d) {
+		if pc := p.Board[to]; pc.Type != NoPieceType {
+			return pc
+		}
+	}
+	return NoPiece
+}
+
+func (p *Position) kingAttacked(c Color) bool {
+	king := p.KingSquare(c)
+	if king == NoSquare || p.Variant == VariantAntichess {
+		return false
+	}
+	if p.Variant == VariantAtomic {
+		if p.kingsAdjacent() || p.KingSquare(c.Other()) == NoSquare {
+			return false
+		}
+		return p.isAttacked(king, c.Other(), false)
+	}
+	return p.IsAttacked(king, c.Other())
+}
+
+func (p *Position) InCheck() bool {
+	return p.kingAttacked(p.Turn)
+}
+
+func (p *Position) pseudoLegalMoves() []Move {
+	moves := make([]Move, 0, 48)
+	us := p.Turn
+	for i, pc := range p.Board {
+		if pc.Type == NoPieceType || pc.Color != us {
+			continue
+		}
+		from := Square(i)
+		switch pc.Type {
+		case Pawn:
+			moves = p.pawnMoves(moves, from)
+		case Knight:
+			moves = p.stepMoves(moves, from, knightSteps)
+		case Bishop:
+			moves = p.slideMoves(moves, from, bishopRays)
+		case Rook:
+			moves = p.slideMoves(moves, from, rookRays)
+		case Queen:
+			moves = p.slideMoves(moves, from, bishopRays)
+			moves = p.slideMoves(moves, from, rookRays)
+		case King:
+			moves = p.stepMoves(moves, from, kingSteps)
+			if p.Variant != VariantAntichess {
+				moves = p.castlingMoves(moves, from)
+			}
+		}
+	}
+	if p.Variant == VariantCrazyhouse {
+		moves = p.dropMoves(moves)
+	}
+	return moves
+}
+
+func (p *Position) addPawnMove(moves []Move, from, to Square) []Move {
+	if to.Rank() == 0 || to.Rank() == 7 {
+		for _, pt := range promotionTos {
+			moves = append(moves, Move{From: from, To: to, Promotion: pt})
+		}
+		if p.Variant == VariantAntichess {
+			moves = append(moves, Move{From: from, To: to, Promotion: King})
+		}
+		return moves
+	}
+	return append(moves, Move{From: from, To: to})
+}
+
+func (p *Position) pawnMoves(moves []Move, from Square) []Move {
+	us := p.Turn
+	dir := pawnDirection(us)
+	if to := from.step(direction{0, dir}); to != NoSquare && p.Board[to].Type == NoPieceType {
+		moves = p.addPawnMove(moves, from, to)
+		startRank := 1
+		if us == Black {
+			startRank = 6
+		}
+		hordeStart := p.Variant == VariantHorde && us == White && from.Rank() == 0
+		if from.Rank() == startRank || hordeStart {
+			if to2 := to.step(direction{0, dir}); p.Board[to2].Type == NoPieceType {
+				moves = append(moves, Move{From: from, To: to2})
+			}
+		}
+	}
+	for _, df := range []int{-1, 1} {
+		to := from.step(direction{df, dir})
+		if to == NoSquare {
+			continue
+		}
+		if target := p.Board[to]; target.Type != NoPieceType && target.Color != us {
+			moves = p.addPawnMove(moves, from, to)
+		} else if to == p.EpSquare && target.Type == NoPieceType {
+			moves = append(moves, Move{From: from, To: to})
+		}
+	}
+	return moves
+}
+
+func (p *Position) stepMoves(moves []Move, from Square, steps []direction) []Move {
+	kingCantCapture := p.Variant == VariantAtomic && p.Board[from].Type == King
+	for _, d := range steps {
+		to := from.step(d)
+		if to == NoSquare {
+			continue
+		}
+		target := p.Board[to]
+		if target.Type == NoPieceType || (target.Color != p.Turn && !kingCantCapture) {
+			moves = append(moves, Move{From: from, To: to})
+		}
+	}
+	return moves
+}
+
+func (p *Position) slideMoves(moves []Move, from Square, rays []direction) []Move {
+	for _, d := range rays {
+		for to := from.step(d); to != NoSquare; to = to.step(d) {
+			target := p.Board[to]
+			if target.Type == NoPieceType {
+				moves = append(moves, Move{From: from, To: to})
+				continue
+			}
+			if target.Color != p.Turn {
+				moves = append(moves, Move{From: from, To: to})
+			}
+			break
+		}
+	}
+	return moves
+}
+
+func castlingTargets(side int, rank int) (king, rook Square) {
+	if side == KingSide {
+		return NewSquare(6, rank), NewSquare(5, rank)
+	}
+	return NewSquare(2, rank), NewSquare(3, rank)
+}
+
+func (p *Position) castlingMoves(moves []Move, king Square) []Move {
+	us := p.Turn
+	for _, side := range []int{KingSide, QueenSide} {
+		rook := p.CastlingRooks[us][side]
+		if rook == NoSquare || rook.Rank() != king.Rank() {
+			continue
+		}
+		kingTo, rookTo := castlingTargets(side, king.Rank())
+		if !p.castlingPathClear(king, rook, kingTo, rookTo) {
+			continue
+		}
+		if p.castlingPathAttacked(king, kingTo) {
+			continue
+		}
+		moves = append(moves, Move{From: king, To: rook, Castling: true})
+	}
+	return moves
+}
+
+func squaresBetween(a, b Square) []Square {
+	lo, hi := a, b
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	var squares []Square
+	for sq := lo; sq <= hi; sq++ {
+		squares = append(squares, sq)
+	}
+	return squares
+}
+
+func (p *Position) castlingPathClear(king, rook, kingTo, rookTo Square) bool {
+	for _, path := range [][]Square{squaresBetween(king, kingTo), squaresBetween(rook, rookTo)} {
+		for _, sq := range path {
+			if sq != king && sq != rook && p.Board[sq].Type != NoPieceType {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *Position) castlingPathAttacked(king, kingTo Square) bool {
+	them := p.Turn.Other()
+	for _, sq := range squaresBetween(king, kingTo) {
+		if p.Variant != VariantAtomic {
+			if p.IsAttacked(sq, them) {
+				return true
+			}
+			continue
+		}
+		probe := p.Copy()
+		probe.Board[king] = NoPiece
+		probe.Board[sq] = Piece{Type: King, Color: p.Turn}
+		if probe.kingAttacked(p.Turn) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Position) isLegal(m Move) bool {
+	switch p.Variant {
+	case VariantAntichess:
+		return true
+	case VariantAtomic:
+		next := p.Play(m)
+		if next.KingSquare(p.Turn) == NoSquare {
+			return false
+		}
+		return !next.kingAttacked(p.Turn)
+	case VariantRacingKings:
+		next := p.Play(m)
+		return !next.kingAttacked(p.Turn) && !next.kingAttacked(next.Turn)
+	}
+	return p.leavesKingSafe(m)
+}
+
+func (p *Position) leavesKingSafe(m Move) bool {
+	return !p.Play(m).kingAttacked(p.Turn)
+}
+
+func (p *Position) isCapture(m Move) bool {
+	if m.Castling || m.Drop != NoPieceType {
+		return false
+	}
+	if p.Board[m.To].Type != NoPieceType {
+		return true
+	}
+	return p.Board[m.From].Type == Pawn && m.From.File() != m.To.File()
+}
+
+func (p *Position) generateLegal() []Move {
+	pseudo := p.pseudoLegalMoves()
+	legal := pseudo[:0]
+	for _, m := range pseudo {
+		if p.isLegal(m) {
+			legal = append(legal, m)
+		}
+	}
+	if p.Variant == VariantAntichess {
+		captures := make([]Move, 0, len(legal))
+		for _, m := range legal {
+			if p.isCapture(m) {
+				captures = append(captures, m)
+			}
+		}
+		if len(captures) > 0 {
+			return captures
+		}
+	}
+	return legal
+}
+
+func (p *Position) LegalMoves() []Move {
+	if p.variantOutcome().Over() {
+		return nil
+	}
+	return p.generateLegal()
+}
+
+func (p *Position) HasLegalMoves() bool {
+	return len(p.LegalMoves()) > 0
+}
+
+// IsCheckmate reports a checkmate. A variant win that comes with a check,
+// such as a third check, is not one.
+func (p *Position) IsCheckmate() bool {
+	return p.InCheck() && !p.variantOutcome().Over() && len(p.generateLegal()) == 0
+}
+
+func (p *Position) IsStalemate() bool {
+	return !p.InCheck() && !p.HasLegalMoves() && !p.variantOutcome().Over()
+}
+
+func (p *Position) Play(m Move) *Position {
+	next := p.Copy()
+	next.apply(m)
+	return next
+}
+
+func (p *Position) apply(m Move) {
+	us := p.Turn
+	moving := p.Board[m.From]
+	captured := p.Board[m.To]
+	capturedPromoted := p.Promoted[m.To]
+	ep := p.EpSquare
+	p.EpSquare = NoSquare
+	p.HalfmoveClock++
+
+	switch {
+	case m.Drop != NoPieceType:
+		p.Pockets[us][m.Drop]--
+		p.Board[m.To] = Piece{Type: m.Drop, Color: us}
+		moving = p.Board[m.To]
+		captured = NoPiece
+		if m.Drop == Pawn {
+			p.HalfmoveClock = 0
+		}
+	case m.Castling:
+		side := KingSide
+		if m.To.File() < m.From.File() {
+			side = QueenSide
+		}
+		kingTo, rookTo := castlingTargets(side, m.From.Rank())
+		rook := p.Board[m.To]
+		p.Board[m.From] = NoPiece
+		p.Board[m.To] = NoPiece
+		p.Board[kingTo] = moving
+		p.Board[rookTo] = rook
+		p.Promoted[m.From], p.Promoted[m.To] = false, false
+		captured = NoPiece
+	case moving.Type == Pawn:
+		p.HalfmoveClock = 0
+		if m.To == ep && captured.Type == NoPieceType && m.From.File() != m.To.File() {
+			epPawn := NewSquare(m.To.File(), m.From.Rank())
+			captured = p.Board[epPawn]
+			p.Board[epPawn] = NoPiece
+		}
+		if d := m.To.Rank() - m.From.Rank(); d == 2 || d == -2 {
+			p.EpSquare = NewSquare(m.From.File(), (m.From.Rank()+m.To.Rank())/2)
+		}
+		p.Board[m.From] = NoPiece
+		p.Promoted[m.To] = false
+		if m.Promotion != NoPieceType {
+			moving.Type = m.Promotion
+			p.Promoted[m.To] = true
+		}
+		p.Board[m.To] = moving
+	default:
+		p.Board[m.From] = NoPiece
+		p.Board[m.To] = moving
+		p.Promoted[m.To] = p.Promoted[m.From]
+		p.Promoted[m.From] = false
+	}
+
+	if captured.Type != NoPieceType {
+		p.HalfmoveClock = 0
+		switch p.Variant {
+		case VariantCrazyhouse:
+			if capturedPromoted {
+				p.Pockets[us][Pawn]++
+			} else {
+				p.Pockets[us][captured.Type]++
+			}
+		case VariantAtomic:
+			p.explode(m.To)
+			for _, c := range []Color{White, Black} {
+				if p.KingSquare(c) == NoSquare {
+					p.CastlingRooks[c] = [2]Square{NoSquare, NoSquare}
+				}
+			}
+		}
+	}
+	if moving.Type == King && m.Drop == NoPieceType {
+		p.CastlingRooks[us] = [2]Square{NoSquare, NoSquare}
+	}
+	for c := range p.CastlingRooks {
+		for side, rook := range p.CastlingRooks[c] {
+			if rook == NoSquare {
+				continue
+			}
+			if rook == m.From || (rook == m.To && !m.Castling) || p.Board[rook] != (Piece{Type: Rook, Color: Color(c)}) {
+				p.CastlingRooks[c][side] = NoSquare
+			}
+		}
+	}
+
+	if us == Black {
+		p.FullmoveNumber++
+	}
+	p.Turn = us.Other()
+
+	if p.Variant == VariantThreeCheck && p.kingAttacked(p.Turn) {
+		p.ChecksGiven[us]++
+	}
+}
+
+func (p *Position) hasEpCapture() bool {
+	if p.EpSquare == NoSquare {
+		return false
+	}
+	for _, df := range []int{-1, 1} {
+		from := p.EpSquare.step(direction{df, -pawnDirection(p.Turn)})
+		if from == NoSquare || p.Board[from] != (Piece{Type: Pawn, Color: p.Turn}) {
+			continue
+		}
+		for _, m := range p.LegalMoves() {
+			if m.From == from && m.To == p.EpSquare {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Position) Perft(depth int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := p.LegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, m := range moves {
+		nodes += p.Play(m).Perft(depth - 1)
+	}
+	return nodes
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	sanRe     = regexp.MustCompile(`^([NBRQK])?([a-h])?([1-8])?(x)?([a-h][1-8])(?:=?([NBRQK]))?$`)
+	sanDropRe = regexp.MustCompile(`^([PNBRQ])?@([a-h][1-8])$`)
+)
+
+func (m Move) String() string {
+	if m.From == NoSquare {
+		return "0000"
+	}
+	if m.Drop != NoPieceType {
+		return m.Drop.String() + "@" + m.To.String()
+	}
+	s := m.From.String() + m.To.String()
+	if m.Promotion != NoPieceType {
+		s += strings.ToLower(m.Promotion.String())
+	}
+	return s
+}
+
+func (p *Position) isStandardCastling(m Move) bool {
+	return p.Variant != VariantChess960 && m.From.File() == 4 && (m.To.File() == 0 || m.To.File() == 7)
+}
+
+func (p *Position) UCI(m Move) string {
+	if m.Castling && p.isStandardCastling(m) {
+		side := KingSide
+		if m.To.File() < m.From.File() {
+			side = QueenSide
+		}
+		kingTo, _ := castlingTargets(side, m.From.Rank())
+		return m.From.String() + kingTo.String()
+	}
+	return m.String()
+}
+
+func (p *Position) ParseUCI(s string) (Move, error) {
+	if len(s) == 4 && s[1] == '@' {
+		return p.parseDrop(s[:1], s[2:], s)
+	}
+	if len(s) < 4 || len(s) > 5 {
+		return NullMove, fmt.Errorf("invalid UCI move %q", s)
+	}
+	from, err := ParseSquare(s[0:2])
+	if err != nil {
+		return NullMove, fmt.Errorf("invalid UCI move %q", s)
+	}
+	to, err := ParseSquare(s[2:4])
+	if err != nil {
+		return NullMove, fmt.Errorf("invalid UCI move %q", s)
+	}
+	promotion := NoPieceType
+	if len(s) == 5 {
+		promotion = pieceTypeFromLetter(s[4])
+		if promotion == NoPieceType || promotion == Pawn {
+			return NullMove, fmt.Errorf("invalid UCI move %q", s)
+		}
+	}
+
+	legal := p.LegalMoves()
+	for _, m := range legal {
+		if m.From == from && m.To == to && m.Promotion == promotion {
+			return m, nil
+		}
+	}
+	for _, m := range legal {
+		if !m.Castling || m.From != from || p.Variant == VariantChess960 {
+			continue
+		}
+		side := KingSide
+		if m.To.File() < m.From.File() {
+			side = QueenSide
+		}
+		if kingTo, _ := castlingTargets(side, from.Rank()); kingTo == to {
+			return m, nil
+		}
+	}
+	return NullMove, fmt.Errorf("illegal move %s in %s", s, p.Fen())
+}
+
+func (p *Position) parseDrop(piece, square, s string) (Move, error) {
+	pt := Pawn
+	if piece != "" {
+		pt = pieceTypeFromLetter(piece[0])
+	}
+	to, err := ParseSquare(square)
+	if err != nil {
+		return NullMove, fmt.Errorf("invalid drop %q", s)
+	}
+	for _, m := range p.LegalMoves() {
+		if m.Drop == pt && m.To == to {
+			return m, nil
+		}
+	}
+	return NullMove, fmt.Errorf("illegal move %s in %s", s, p.Fen())
+}
+
+func (p *Position) SAN(m Move) string {
+	san := p.sanWithoutSuffix(m)
+	next := p.Play(m)
+	if next.IsCheckmate() {
+		san += "#"
+	} else if next.InCheck() {
+		san += "+"
+	}
+	return san
+}
+
+func (p *Position) sanWithoutSuffix(m Move) string {
+	if m.Drop != NoPieceType {
+		return m.String()
+	}
+	if m.Castling {
+		if m.To.File() < m.From.File() {
+			return "O-O-O"
+		}
+		return "O-O"
+	}
+
+	piece := p.Board[m.From]
+	capture := p.Board[m.To].Type != NoPieceType || (piece.Type == Pawn && m.From.File() != m.To.File())
+
+	var sb strings.Builder
+	if piece.Type == Pawn {
+		if capture {
+			sb.WriteByte(byte('a' + m.From.File()))
+		}
+	} else {
+		sb.WriteString(piece.Type.String())
+		sb.WriteString(p.disambiguation(m, piece.Type))
+	}
+	if capture {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(m.To.String())
+	if m.Promotion != NoPieceType {
+		sb.WriteByte('=')
+		sb.WriteString(m.Promotion.String())
+	}
+	return sb.String()
+}
+
+func (p *Position) disambiguation(m Move, pt PieceType) string {
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range p.LegalMoves() {
+		if other.Castling || other.Drop != NoPieceType || other.To != m.To || other.From == m.From || p.Board[other.From].Type != pt {
+			continue
+		}
+		ambiguous = true
+		if other.From.File() == m.From.File() {
+			sameFile = true
+		}
+		if other.From.Rank() == m.From.Rank() {
+			sameRank = true
+		}
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return m.From.String()[:1]
+	case !sameRank:
+		return m.From.String()[1:]
+	}
+	return m.From.String()
+}
+
+func (p *Position) ParseSAN(san string) (Move, error) {
+	s := strings.TrimRight(san, "+#!?")
+	switch s {
+	case "O-O", "0-0", "O-O-O", "0-0-0":
+		side := KingSide
+		if len(s) == 5 {
+			side = QueenSide
+		}
+		for _, m := range p.LegalMoves() {
+			if m.Castling && (m.To.File() < m.From.File()) == (side == QueenSide) {
+				return m, nil
+			}
+		}
+		return NullMove, fmt.Errorf("illegal move %s in %s", san, p.Fen())
+	}
+
+	if drop := sanDropRe.FindStringSubmatch(s); drop != nil {
+		return p.parseDrop(drop[1], drop[2], san)
+	}
+
+	parts := sanRe.FindStringSubmatch(s)
+	if parts == nil {
+		return NullMove, fmt.Errorf("invalid SAN move %q", san)
+	}
+	pt := Pawn
+	if parts[1] != "" {
+		pt = pieceTypeFromLetter(parts[1][0])
+	}
+	to, _ := ParseSquare(parts[5])
+	promotion := NoPieceType
+	if parts[6] != "" {
+		promotion = pieceTypeFromLetter(parts[6][0])
+	}
+
+	match := NullMove
+	for _, m := range p.LegalMoves() {
+		if m.Castling || m.Drop != NoPieceType || m.To != to || p.Board[m.From].Type != pt || m.Promotion != promotion {
+			continue
+		}
+		if parts[2] != "" && m.From.File() != int(parts[2][0]-'a') {
+			continue
+		}
+		if parts[3] != "" && m.From.Rank() != int(parts[3][0]-'1') {
+			continue
+		}
+		if match != NullMove {
+			return NullMove, fmt.Errorf("ambiguous move %s in %s", san, p.Fen())
+		}
+		match = m
+	}
+	if match == NullMove {
+		return NullMove, fmt.Errorf("illegal move %s in %s", san, p.Fen())
+	}
+	return match, nil
+}
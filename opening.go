@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed openings.tsv
+var openingsTsv string
+
+type Opening struct {
+	Eco  string `json:"eco"`
+	Name string `json:"name"`
+	Ply  int    `json:"ply"`
+}
+
+type OpeningTable struct {
+	Openings []Opening
+	byHash   map[uint64]int
+}
+
+var (
+	defaultOpenings     *OpeningTable
+	defaultOpeningsOnce sync.Once
+)
+
+// DefaultOpenings returns the embedded table of Lichess openings.
+func DefaultOpenings() *OpeningTable {
+	defaultOpeningsOnce.Do(func() {
+		table, err := NewOpeningTable(strings.NewReader(openingsTsv))
+		if err != nil {
+			panic(err)
+		}
+		defaultOpenings = table
+	})
+	return defaultOpenings
+}
+
+// NewOpeningTable reads a tab separated table with eco, name, pgn and epd
+// columns, as published in the lichess-org/chess-openings repository.
+func NewOpeningTable(r io.Reader) (*OpeningTable, error) {
+	table := &OpeningTable{byHash: make(map[uint64]int)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if line == 1 && fields[0] == "eco" {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("openings line %d: expected 4 columns, got %d", line, len(fields))
+		}
+		pos, err := ParseFen(fields[3] + " 0 1")
+		if err != nil {
+			return nil, fmt.Errorf("openings line %d: %v", line, err)
+		}
+		ply := 0
+		for _, tok := range strings.Fields(fields[2]) {
+			if !strings.HasSuffix(tok, ".") {
+				ply++
+			}
+		}
+
+		hash := pos.Hash()
+		if i, ok := table.byHash[hash]; ok && table.Openings[i].Ply <= ply {
+			continue
+		}
+		table.byHash[hash] = len(table.Openings)
+		table.Openings = append(table.Openings, Opening{Eco: fields[0], Name: fields[1], Ply: ply})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func (t *OpeningTable) Lookup(p *Position) (Opening, bool) {
+	i, ok := t.byHash[p.Hash()]
+	if !ok {
+		return Opening{}, false
+	}
+	return t.Openings[i], true
+}
+
+// Classify returns the opening of the deepest position in the game that
+// appears in the table, with Ply set to the ply at which it was reached.
+// Positions are matched by hash so transpositions are recognized.
+func (t *OpeningTable) Classify(r *ReplayedGame) (Opening, bool) {
+	if r.Initial.Variant != VariantStandard && r.Initial.Variant != VariantFromPosition {
+		return Opening{}, false
+	}
+
+	var found Opening
+	ok := false
+	for _, ply := range r.Plies {
+		if i, match := t.byHash[ply.Hash]; match {
+			found = t.Openings[i]
+			found.Ply = ply.Number
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+func (t *OpeningTable) ClassifyMoves(moves string) (Opening, bool) {
+	plies, _ := Replay("", moves)
+	return t.Classify(&ReplayedGame{Initial: NewPosition(), Plies: plies})
+}
+
+// ClassifyOpening fills game.Opening from the embedded table when Lichess
+// did not provide it and reports whether an opening was found.
+func ClassifyOpening(game *Game) bool {
+	if game.Opening.Eco != "" {
+		return true
+	}
+	replayed, _ := ReplayGame(game)
+	if replayed == nil {
+		return false
+	}
+	opening, ok := DefaultOpenings().Classify(replayed)
+	if ok {
+		game.Opening = opening
+	}
+	return ok
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyOpening(t *testing.T) {
+	openings := DefaultOpenings()
+	if len(openings.Openings) < 3000 {
+		t.Fatalf("Expected the embedded table to be loaded, got %d openings", len(openings.Openings))
+	}
+
+	opening, ok := openings.ClassifyMoves("e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7 Re1 b5 Bb3 d6 c3 O-O h3 Na5 Bc2 c5 d4 Qc7 a4")
+	if !ok || opening.Eco != "C97" || opening.Ply != 22 {
+		t.Errorf("Expected C97 at ply 22, got %+v", opening)
+	}
+
+	opening, ok = openings.ClassifyMoves("e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6 Qd3 h6")
+	if !ok || !strings.HasPrefix(opening.Name, "Sicilian Defense: Najdorf Variation") || opening.Ply != 10 {
+		t.Errorf("Expected the Najdorf at ply 10, got %+v", opening)
+	}
+
+	direct, _ := openings.ClassifyMoves("d4 Nf6 c4 e6 Nc3 Bb4")
+	transposed, ok := openings.ClassifyMoves("c4 e6 Nc3 Nf6 d4 Bb4")
+	if !ok || transposed.Eco != "E20" || transposed.Name != direct.Name {
+		t.Errorf("Expected the Nimzo-Indian by transposition, got %+v and %+v", transposed, direct)
+	}
+
+	if _, ok := openings.ClassifyMoves(""); ok {
+		t.Errorf("Expected no opening without moves")
+	}
+}
+
+func TestClassifyGame(t *testing.T) {
+	game := Game{Moves: "d4 d5 c4 e6 Nc3 Nf6 Bg5"}
+	if !ClassifyOpening(&game) || game.Opening.Eco != "D50" || game.Opening.Ply != 7 {
+		t.Errorf("Unexpected opening %+v", game.Opening)
+	}
+
+	game = Game{Moves: "e4 e5", Opening: Opening{Eco: "C20", Name: "King's Pawn Game", Ply: 2}}
+	if !ClassifyOpening(&game) || game.Opening.Name != "King's Pawn Game" {
+		t.Errorf("Expected the Lichess opening to be kept, got %+v", game.Opening)
+	}
+
+	game = Game{Variant: VariantAtomic, Moves: "e4 e5"}
+	if ClassifyOpening(&game) {
+		t.Errorf("Expected variants not to be classified, got %+v", game.Opening)
+	}
+}
@@ -0,0 +1,833 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type PgnTag struct {
+	Name  string
+	Value string
+}
+
+type PgnTags []PgnTag
+
+func (t PgnTags) Get(name string) string {
+	for _, tag := range t {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+func (t *PgnTags) Set(name, value string) {
+	for i := range *t {
+		if (*t)[i].Name == name {
+			(*t)[i].Value = value
+			return
+		}
+	}
+	*t = append(*t, PgnTag{Name: name, Value: value})
+}
+
+type PgnEval struct {
+	CP   int
+	Mate int
+}
+
+type PgnNode struct {
+	Move             string
+	NAGs             []int
+	StartingComments []string
+	Comments         []string
+	Clock            *time.Duration
+	Eval             *PgnEval
+	Parent           *PgnNode
+	Children         []*PgnNode
+}
+
+func (n *PgnNode) AddChild(move string) *PgnNode {
+	child := &PgnNode{Move: move, Parent: n}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+func (n *PgnNode) Ply() int {
+	ply := 0
+	for p := n; p.Parent != nil; p = p.Parent {
+		ply++
+	}
+	return ply
+}
+
+type PgnGame struct {
+	Tags   PgnTags
+	Root   *PgnNode
+	Result string
+}
+
+func NewEmptyPgnGame() *PgnGame {
+	return &PgnGame{Root: &PgnNode{}, Result: "*"}
+}
+
+func (g *PgnGame) MainLine() []*PgnNode {
+	var line []*PgnNode
+	for n := g.Root; len(n.Children) > 0; {
+		n = n.Children[0]
+		line = append(line, n)
+	}
+	return line
+}
+
+var (
+	pgnSuffixNags = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+	pgnCommandRe  = regexp.MustCompile(`\[%(\w+)\s+([^\]]*)\]`)
+)
+
+type pgnTokenKind int
+
+const (
+	pgnTokEOF pgnTokenKind = iota
+	pgnTokTag
+	pgnTokComment
+	pgnTokOpen
+	pgnTokClose
+	pgnTokNag
+	pgnTokMoveNumber
+	pgnTokResult
+	pgnTokSymbol
+)
+
+type pgnToken struct {
+	kind  pgnTokenKind
+	text  string
+	value string
+	nag   int
+	line  int
+}
+
+type PgnReader struct {
+	r         *bufio.Reader
+	line      int
+	lineStart bool
+	prevStart bool
+	peeked    *pgnToken
+}
+
+func NewPgnReader(r io.Reader) *PgnReader {
+	return &PgnReader{r: bufio.NewReader(r), line: 1, lineStart: true}
+}
+
+func ParsePgn(pgn string) (*PgnGame, error) {
+	return NewPgnReader(strings.NewReader(pgn)).Next()
+}
+
+func (pr *PgnReader) Next() (*PgnGame, error) {
+	game := NewEmptyPgnGame()
+	game.Result = ""
+	cur := game.Root
+	var stack []*PgnNode
+	var pending []string
+	varStart := false
+	empty := true
+
+	for {
+		tok, err := pr.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case pgnTokEOF:
+			if empty {
+				return nil, io.EOF
+			}
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("pgn: line %d: unexpected end of input inside variation", tok.line)
+			}
+			return pr.finish(game), nil
+		case pgnTokTag:
+			if len(game.Root.Children) > 0 || len(game.Root.Comments) > 0 {
+				pr.peeked = &tok
+				if len(stack) > 0 {
+					return nil, fmt.Errorf("pgn: line %d: unterminated variation", tok.line)
+				}
+				return pr.finish(game), nil
+			}
+			game.Tags = append(game.Tags, PgnTag{Name: tok.text, Value: tok.value})
+		case pgnTokComment:
+			switch {
+			case varStart:
+				pending = append(pending, tok.text)
+			case cur == game.Root:
+				cur.Comments = append(cur.Comments, tok.text)
+			default:
+				cur.addComment(tok.text)
+			}
+		case pgnTokNag:
+			if cur != game.Root && !varStart {
+				cur.NAGs = append(cur.NAGs, tok.nag)
+			}
+		case pgnTokOpen:
+			if cur == game.Root || varStart {
+				pr.skipGame()
+				return nil, fmt.Errorf("pgn: line %d: variation without a preceding move", tok.line)
+			}
+			stack = append(stack, cur)
+			cur = cur.Parent
+			varStart = true
+		case pgnTokClose:
+			if len(stack) == 0 || varStart {
+				pr.skipGame()
+				return nil, fmt.Errorf("pgn: line %d: unbalanced or empty variation", tok.line)
+			}
+			cur = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		case pgnTokMoveNumber:
+		case pgnTokResult:
+			if len(stack) > 0 {
+				pr.skipGame()
+				return nil, fmt.Errorf("pgn: line %d: game result inside variation", tok.line)
+			}
+			game.Result = tok.text
+			return pr.finish(game), nil
+		case pgnTokSymbol:
+			cur = cur.AddChild(tok.text)
+			cur.StartingComments = pending
+			pending = nil
+			varStart = false
+		}
+		empty = false
+	}
+}
+
+func (pr *PgnReader) finish(game *PgnGame) *PgnGame {
+	if game.Result == "" {
+		game.Result = game.Tags.Get("Result")
+	}
+	if game.Result == "" {
+		game.Result = "*"
+	}
+	return game
+}
+
+func (pr *PgnReader) skipGame() {
+	for {
+		tok, err := pr.next()
+		if err != nil || tok.kind == pgnTokEOF {
+			return
+		}
+		if tok.kind == pgnTokTag {
+			pr.peeked = &tok
+			return
+		}
+	}
+}
+
+func (n *PgnNode) addComment(text string) {
+	text = pgnCommandRe.ReplaceAllStringFunc(text, func(cmd string) string {
+		m := pgnCommandRe.FindStringSubmatch(cmd)
+		switch m[1] {
+		case "clk":
+			if d, err := ParsePgnClock(m[2]); err == nil {
+				n.Clock = &d
+				return ""
+			}
+		case "eval":
+			if e, err := ParsePgnEval(m[2]); err == nil {
+				n.Eval = e
+				return ""
+			}
+		}
+		return cmd
+	})
+	text = strings.Join(strings.Fields(text), " ")
+	if text != "" {
+		n.Comments = append(n.Comments, text)
+	}
+}
+
+func ParsePgnClock(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	var total float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid clock %q", s)
+		}
+		total = total*60 + v
+	}
+	return time.Duration(math.Round(total*float64(time.Second/time.Millisecond))) * time.Millisecond, nil
+}
+
+func ParsePgnEval(s string) (*PgnEval, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		s = s[:i]
+	}
+	if strings.HasPrefix(s, "#") {
+		mate, err := strconv.Atoi(s[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid eval %q", s)
+		}
+		return &PgnEval{Mate: mate}, nil
+	}
+	pawns, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid eval %q", s)
+	}
+	return &PgnEval{CP: int(math.Round(pawns * 100))}, nil
+}
+
+func (pr *PgnReader) readRune() (rune, error) {
+	r, _, err := pr.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	pr.prevStart = pr.lineStart
+	if r == '\n' {
+		pr.line++
+		pr.lineStart = true
+	} else {
+		pr.lineStart = false
+	}
+	return r, nil
+}
+
+func (pr *PgnReader) unreadRune(r rune) {
+	_ = pr.r.UnreadRune()
+	pr.lineStart = pr.prevStart
+	if r == '\n' {
+		pr.line--
+	}
+}
+
+func (pr *PgnReader) skipLine() error {
+	for {
+		r, err := pr.readRune()
+		if err != nil || r == '\n' {
+			return err
+		}
+	}
+}
+
+func isPgnSymbolRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_+#=:-/@", r)
+}
+
+func (pr *PgnReader) next() (pgnToken, error) {
+	if pr.peeked != nil {
+		tok := *pr.peeked
+		pr.peeked = nil
+		return tok, nil
+	}
+
+	for {
+		atLineStart := pr.lineStart
+		r, err := pr.readRune()
+		if err == io.EOF {
+			return pgnToken{kind: pgnTokEOF, line: pr.line}, nil
+		}
+		if err != nil {
+			return pgnToken{}, err
+		}
+		line := pr.line
+
+		switch {
+		case unicode.IsSpace(r) || r == '\ufeff':
+		case r == '%' && atLineStart:
+			if err := pr.skipLine(); err != nil && err != io.EOF {
+				return pgnToken{}, err
+			}
+		case r == ';':
+			var sb strings.Builder
+			for {
+				r, err := pr.readRune()
+				if err != nil || r == '\n' {
+					break
+				}
+				sb.WriteRune(r)
+			}
+			return pgnToken{kind: pgnTokComment, text: strings.TrimSpace(sb.String()), line: line}, nil
+		case r == '{':
+			var sb strings.Builder
+			for {
+				r, err := pr.readRune()
+				if err == io.EOF {
+					return pgnToken{}, fmt.Errorf("pgn: line %d: unterminated comment", line)
+				}
+				if err != nil {
+					return pgnToken{}, err
+				}
+				if r == '}' {
+					break
+				}
+				sb.WriteRune(r)
+			}
+			return pgnToken{kind: pgnTokComment, text: strings.TrimSpace(sb.String()), line: line}, nil
+		case r == '[':
+			return pr.readTag(line)
+		case r == '(':
+			return pgnToken{kind: pgnTokOpen, line: line}, nil
+		case r == ')':
+			return pgnToken{kind: pgnTokClose, line: line}, nil
+		case r == '*':
+			return pgnToken{kind: pgnTokResult, text: "*", line: line}, nil
+		case r == '$':
+			digits := pr.readWhile(unicode.IsDigit)
+			nag, err := strconv.Atoi(digits)
+			if err != nil {
+				return pgnToken{}, fmt.Errorf("pgn: line %d: invalid NAG", line)
+			}
+			return pgnToken{kind: pgnTokNag, nag: nag, line: line}, nil
+		case r == '!' || r == '?':
+			suffix := string(r) + pr.readWhile(func(r rune) bool { return r == '!' || r == '?' })
+			if nag, ok := pgnSuffixNags[suffix]; ok {
+				return pgnToken{kind: pgnTokNag, nag: nag, line: line}, nil
+			}
+			return pgnToken{}, fmt.Errorf("pgn: line %d: invalid annotation %q", line, suffix)
+		case isPgnSymbolRune(r):
+			text := string(r) + pr.readWhile(isPgnSymbolRune)
+			switch text {
+			case "1-0", "0-1", "1/2-1/2":
+				return pgnToken{kind: pgnTokResult, text: text, line: line}, nil
+			}
+			if isAllDigits(text) {
+				dots := pr.readWhile(func(r rune) bool { return r == '.' })
+				if dots != "" || text != "0" {
+					return pgnToken{kind: pgnTokMoveNumber, text: text, line: line}, nil
+				}
+			}
+			return pgnToken{kind: pgnTokSymbol, text: text, line: line}, nil
+		case r == '.':
+		default:
+			return pgnToken{}, fmt.Errorf("pgn: line %d: unexpected character %q", line, r)
+		}
+	}
+}
+
+func (pr *PgnReader) readWhile(pred func(rune) bool) string {
+	var sb strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return sb.String()
+		}
+		if !pred(r) {
+			pr.unreadRune(r)
+			return sb.String()
+		}
+		sb.WriteRune(r)
+	}
+}
+
+func (pr *PgnReader) readTag(line int) (pgnToken, error) {
+	pr.readWhile(unicode.IsSpace)
+	name := pr.readWhile(func(r rune) bool { return isPgnSymbolRune(r) })
+	if name == "" {
+		return pgnToken{}, fmt.Errorf("pgn: line %d: tag without a name", line)
+	}
+	pr.readWhile(unicode.IsSpace)
+	r, err := pr.readRune()
+	if err != nil || r != '"' {
+		return pgnToken{}, fmt.Errorf("pgn: line %d: tag %s has no quoted value", line, name)
+	}
+
+	var sb strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil || r == '\n' {
+			return pgnToken{}, fmt.Errorf("pgn: line %d: unterminated tag value", line)
+		}
+		if r == '\\' {
+			r, err = pr.readRune()
+			if err != nil {
+				return pgnToken{}, fmt.Errorf("pgn: line %d: unterminated tag value", line)
+			}
+		} else if r == '"' {
+			break
+		}
+		sb.WriteRune(r)
+	}
+
+	pr.readWhile(unicode.IsSpace)
+	if r, err := pr.readRune(); err != nil || r != ']' {
+		return pgnToken{}, fmt.Errorf("pgn: line %d: tag %s is not closed", line, name)
+	}
+	return pgnToken{kind: pgnTokTag, text: name, value: sb.String(), line: line}, nil
+}
+
+func isAllDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+var (
+	variantTagNames = map[string]string{
+		"standard":      "Standard",
+		"chess960":      "Chess960",
+		"fromPosition":  "From Position",
+		"antichess":     "Antichess",
+		"atomic":        "Atomic",
+		"crazyhouse":    "Crazyhouse",
+		"horde":         "Horde",
+		"kingOfTheHill": "King of the Hill",
+		"racingKings":   "Racing Kings",
+		"threeCheck":    "Three-check",
+	}
+	perfTagNames = map[string]string{
+		"ultraBullet":    "UltraBullet",
+		"bullet":         "Bullet",
+		"blitz":          "Blitz",
+		"rapid":          "Rapid",
+		"classical":      "Classical",
+		"correspondence": "Correspondence",
+	}
+	terminationTags = map[string]string{
+		"mate":          "Normal",
+		"resign":        "Normal",
+		"stalemate":     "Normal",
+		"draw":          "Normal",
+		"variantEnd":    "Normal",
+		"outoftime":     "Time forfeit",
+		"timeout":       "Abandoned",
+		"aborted":       "Abandoned",
+		"noStart":       "Abandoned",
+		"cheat":         "Rules infraction",
+		"started":       "Unterminated",
+		"created":       "Unterminated",
+		"unknownFinish": "Unknown",
+	}
+	judgmentNags = map[string]int{"Inaccuracy": 6, "Mistake": 2, "Blunder": 4}
+)
+
+func variantFromTag(tag string) string {
+	for key, name := range variantTagNames {
+		if strings.EqualFold(name, tag) {
+			return key
+		}
+	}
+	switch strings.ToLower(tag) {
+	case "", "normal":
+		return "standard"
+	case "three-check", "3-check", "threecheck":
+		return "threeCheck"
+	case "king of the hill", "koth", "kingofthehill":
+		return "kingOfTheHill"
+	case "racing kings", "racingkings":
+		return "racingKings"
+	}
+	return tag
+}
+
+func GameResult(game *Game) string {
+	switch {
+	case game.Winner == "white":
+		return "1-0"
+	case game.Winner == "black":
+		return "0-1"
+	}
+	switch game.Status {
+	case "", "created", "started", "aborted", "noStart", "unknownFinish":
+		return "*"
+	}
+	return "1/2-1/2"
+}
+
+func gamePlayerName(p GamePlayer) string {
+	if p.AiLevel > 0 {
+		return fmt.Sprintf("lichess AI level %d", p.AiLevel)
+	}
+	if p.User.Name == "" {
+		return "?"
+	}
+	return p.User.Name
+}
+
+func gameTimeControl(game *Game) string {
+	if game.Speed == "correspondence" || (game.Clock.Initial == 0 && game.Clock.Increment == 0) {
+		return "-"
+	}
+	return fmt.Sprintf("%d+%d", game.Clock.Initial, game.Clock.Increment)
+}
+
+func NewPgnGame(game *Game) *PgnGame {
+	pg := NewEmptyPgnGame()
+	pg.Result = GameResult(game)
+
+	event := "Casual"
+	if game.Rated {
+		event = "Rated"
+	}
+	perf := perfTagNames[game.Perf]
+	if perf == "" {
+		perf = variantTagNames[game.Perf]
+	}
+	if perf == "" {
+		perf = perfTagNames[game.Speed]
+	}
+	if perf != "" {
+		event += " " + perf
+	}
+
+	created := time.Unix(0, game.CreatedAt*int64(time.Millisecond)).UTC()
+	date := "????.??.??"
+	if game.CreatedAt > 0 {
+		date = created.Format("2006.01.02")
+	}
+
+	pg.Tags.Set("Event", event+" game")
+	pg.Tags.Set("Site", LichessBase+"/"+game.ID)
+	pg.Tags.Set("Date", date)
+	pg.Tags.Set("Round", "-")
+	pg.Tags.Set("White", gamePlayerName(game.Players.White))
+	pg.Tags.Set("Black", gamePlayerName(game.Players.Black))
+	pg.Tags.Set("Result", pg.Result)
+	if game.CreatedAt > 0 {
+		pg.Tags.Set("UTCDate", date)
+		pg.Tags.Set("UTCTime", created.Format("15:04:05"))
+	}
+	for _, side := range []struct {
+		color  string
+		player GamePlayer
+	}{{"White", game.Players.White}, {"Black", game.Players.Black}} {
+		if side.player.Rating > 0 {
+			pg.Tags.Set(side.color+"Elo", strconv.Itoa(side.player.Rating))
+		}
+	}
+	for _, side := range []struct {
+		color  string
+		player GamePlayer
+	}{{"White", game.Players.White}, {"Black", game.Players.Black}} {
+		if side.player.Rating > 0 && game.Rated {
+			pg.Tags.Set(side.color+"RatingDiff", fmt.Sprintf("%+d", side.player.RatingDiff))
+		}
+	}
+	if game.Players.White.User.Title != "" {
+		pg.Tags.Set("WhiteTitle", game.Players.White.User.Title)
+	}
+	if game.Players.Black.User.Title != "" {
+		pg.Tags.Set("BlackTitle", game.Players.Black.User.Title)
+	}
+	variant := variantTagNames[game.Variant]
+	if variant == "" {
+		variant = "Standard"
+	}
+	pg.Tags.Set("Variant", variant)
+	pg.Tags.Set("TimeControl", gameTimeControl(game))
+	if game.Opening.Eco != "" {
+		pg.Tags.Set("ECO", game.Opening.Eco)
+	}
+	if game.Opening.Name != "" {
+		pg.Tags.Set("Opening", game.Opening.Name)
+	}
+	if term, ok := terminationTags[game.Status]; ok {
+		pg.Tags.Set("Termination", term)
+	}
+	if game.InitialFen != "" {
+		pg.Tags.Set("FEN", game.InitialFen)
+		pg.Tags.Set("SetUp", "1")
+	}
+
+	node := pg.Root
+	for i, san := range strings.Fields(game.Moves) {
+		node = node.AddChild(san)
+		if i < len(game.Clocks) {
+			clock := time.Duration(game.Clocks[i]) * 10 * time.Millisecond
+			node.Clock = &clock
+		}
+		if i < len(game.Analysis) {
+			a := game.Analysis[i]
+			node.Eval = &PgnEval{CP: a.Eval, Mate: a.Mate}
+			if a.Judgment != nil {
+				if nag, ok := judgmentNags[a.Judgment.Name]; ok {
+					node.NAGs = append(node.NAGs, nag)
+				}
+				if a.Judgment.Comment != "" {
+					node.Comments = append(node.Comments, a.Judgment.Comment)
+				}
+			}
+			if a.Variation != "" && node.Parent != nil {
+				alt := node.Parent
+				for _, move := range strings.Fields(a.Variation) {
+					alt = alt.AddChild(move)
+				}
+			}
+		}
+	}
+	return pg
+}
+
+func (g *PgnGame) Game() *Game {
+	var game Game
+	tags := g.Tags
+
+	site := tags.Get("Site")
+	if id := tags.Get("GameId"); id != "" {
+		game.ID = id
+	} else if strings.HasPrefix(site, LichessBase+"/") {
+		game.ID = strings.TrimPrefix(site, LichessBase+"/")
+	}
+
+	event := tags.Get("Event")
+	game.Rated = strings.HasPrefix(strings.ToLower(event), "rated")
+	game.Variant = variantFromTag(tags.Get("Variant"))
+	game.InitialFen = tags.Get("FEN")
+
+	for _, side := range []struct {
+		color  string
+		player *GamePlayer
+	}{{"White", &game.Players.White}, {"Black", &game.Players.Black}} {
+		name := tags.Get(side.color)
+		if strings.HasPrefix(name, "lichess AI level ") {
+			side.player.AiLevel, _ = strconv.Atoi(strings.TrimPrefix(name, "lichess AI level "))
+		} else if name != "?" {
+			side.player.User.Name = name
+			side.player.User.ID = strings.ToLower(name)
+		}
+		side.player.User.Title = tags.Get(side.color + "Title")
+		side.player.Rating, _ = strconv.Atoi(tags.Get(side.color + "Elo"))
+		side.player.RatingDiff, _ = strconv.Atoi(tags.Get(side.color + "RatingDiff"))
+	}
+
+	date := tags.Get("UTCDate")
+	if date == "" {
+		date = tags.Get("Date")
+	}
+	clock := tags.Get("UTCTime")
+	if clock == "" {
+		clock = "00:00:00"
+	}
+	if t, err := time.Parse("2006.01.02 15:04:05", date+" "+clock); err == nil {
+		game.CreatedAt = t.UnixNano() / int64(time.Millisecond)
+	}
+
+	if tc := tags.Get("TimeControl"); tc != "" && tc != "-" {
+		parts := strings.SplitN(tc, "+", 2)
+		game.Clock.Initial, _ = strconv.Atoi(parts[0])
+		if len(parts) == 2 {
+			game.Clock.Increment, _ = strconv.Atoi(parts[1])
+		}
+		game.Clock.TotalTime = game.Clock.Initial + 40*game.Clock.Increment
+		game.Speed = speedFromClock(game.Clock.Initial, game.Clock.Increment)
+	} else {
+		game.Speed = "correspondence"
+	}
+	game.Perf = game.Speed
+	if game.Variant != "standard" && game.Variant != "fromPosition" {
+		game.Perf = game.Variant
+	}
+
+	game.Opening.Eco = tags.Get("ECO")
+	game.Opening.Name = tags.Get("Opening")
+
+	result := g.Result
+	if result == "" || result == "*" {
+		result = tags.Get("Result")
+	}
+	switch result {
+	case "1-0":
+		game.Winner = "white"
+	case "0-1":
+		game.Winner = "black"
+	}
+
+	line := g.MainLine()
+	moves := make([]string, len(line))
+	hasEval := false
+	for i, node := range line {
+		moves[i] = node.Move
+		if node.Eval != nil {
+			hasEval = true
+		}
+	}
+	game.Moves = strings.Join(moves, " ")
+	if len(line) > 0 && line[0].Clock != nil {
+		for _, node := range line {
+			if node.Clock == nil {
+				break
+			}
+			game.Clocks = append(game.Clocks, int(*node.Clock/(10*time.Millisecond)))
+		}
+	}
+	game.Status = statusFromTermination(tags.Get("Termination"), result, moves)
+	if hasEval {
+		// Lichess writes no eval after the mating move, so the analysis
+		// stops at the last eval and a final checkmate is scored as such.
+		last := 0
+		for i, node := range line {
+			if node.Eval != nil {
+				last = i + 1
+			}
+		}
+		game.Analysis = make([]MoveAnalysis, last)
+		for i, node := range line[:last] {
+			if node.Eval != nil {
+				game.Analysis[i].Eval = node.Eval.CP
+				game.Analysis[i].Mate = node.Eval.Mate
+			}
+		}
+		if last == len(line)-1 {
+			if mate, ok := finalMate(&game); ok {
+				game.Analysis = append(game.Analysis, mate)
+			}
+		}
+	}
+	return &game
+}
+
+func speedFromClock(initial, increment int) string {
+	estimate := initial + 40*increment
+	switch {
+	case estimate < 30:
+		return "ultraBullet"
+	case estimate < 180:
+		return "bullet"
+	case estimate < 480:
+		return "blitz"
+	case estimate < 1500:
+		return "rapid"
+	}
+	return "classical"
+}
+
+func statusFromTermination(termination, result string, moves []string) string {
+	switch termination {
+	case "Time forfeit":
+		return "outoftime"
+	case "Abandoned":
+		if result == "*" {
+			return "aborted"
+		}
+		return "timeout"
+	case "Rules infraction":
+		return "cheat"
+	case "Unterminated":
+		return "started"
+	}
+	switch result {
+	case "1/2-1/2":
+		return "draw"
+	case "1-0", "0-1":
+		if len(moves) > 0 && strings.HasSuffix(moves[len(moves)-1], "#") {
+			return "mate"
+		}
+		return "resign"
+	}
+	return "started"
+}
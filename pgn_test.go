@@ -0,0 +1,178 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const lichessPgn = `[Event "Rated Blitz game"]
+[Site "https://lichess.org/XWWk5HG6"]
+[Date "2021.02.20"]
+[White "chess-network"]
+[Black "STL_Nakamura"]
+[Result "0-1"]
+[UTCDate "2021.02.20"]
+[UTCTime "17:51:20"]
+[WhiteElo "2450"]
+[BlackElo "2900"]
+[WhiteRatingDiff "-4"]
+[BlackRatingDiff "+2"]
+[BlackTitle "GM"]
+[Variant "Standard"]
+[TimeControl "180+2"]
+[ECO "C20"]
+[Opening "King's Pawn Game"]
+[Termination "Normal"]
+
+1. e4 { [%eval 0.17] [%clk 0:03:00] } 1... e5 { [%eval 0.2] [%clk 0:03:00] }
+2. Qh5?! { (0.20 → -0.35) Inaccuracy. Nf3 was best. } { [%eval -0.35] [%clk 0:02:58] }
+( 2. Nf3 Nc6 ( 2... d6 3. d4 ) 3. Bb5 ) 2... Nc6 $1 { [%eval #-4] [%clk 0:02:59] } 0-1
+
+[Event "Casual game"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "a \"quoted\" name"]
+[Black "?"]
+[Result "*"]
+
+{ Starting comment } 1. d4 ; rest of line comment
+d5 2. c4 *
+`
+
+func TestPgnReaderMultipleGames(t *testing.T) {
+	r := NewPgnReader(strings.NewReader(lichessPgn))
+
+	first, err := r.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error reading first game: %v", err)
+	}
+	second, err := r.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error reading second game: %v", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last game, got %v", err)
+	}
+
+	if first.Result != "0-1" || first.Tags.Get("BlackTitle") != "GM" || len(first.Tags) != 18 {
+		t.Errorf("Unexpected first game tags: %+v result %s", first.Tags, first.Result)
+	}
+	if second.Tags.Get("White") != `a "quoted" name` {
+		t.Errorf("Expected escaped quotes to be unescaped, got %s", second.Tags.Get("White"))
+	}
+	if second.Result != "*" || len(second.MainLine()) != 3 {
+		t.Errorf("Expected three moves and an unfinished result, got %d and %s", len(second.MainLine()), second.Result)
+	}
+	if second.Root.Comments[0] != "Starting comment" || second.MainLine()[0].Comments[0] != "rest of line comment" {
+		t.Errorf("Expected game and rest-of-line comments, got %v and %v", second.Root.Comments, second.MainLine()[0].Comments)
+	}
+}
+
+func TestPgnReaderAnnotations(t *testing.T) {
+	game, err := ParsePgn(lichessPgn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	line := game.MainLine()
+	if len(line) != 4 || line[2].Move != "Qh5" || line[3].Move != "Nc6" {
+		t.Fatalf("Unexpected main line: %v", line)
+	}
+	if *line[0].Clock != 3*time.Minute || line[0].Eval.CP != 17 || line[1].Eval.CP != 20 {
+		t.Errorf("Expected clock and eval on the first moves, got %v %+v", *line[0].Clock, *line[0].Eval)
+	}
+	if line[3].Eval.Mate != -4 || line[3].NAGs[0] != 1 {
+		t.Errorf("Expected mate score and NAG on the last move, got %+v %v", *line[3].Eval, line[3].NAGs)
+	}
+	if line[2].NAGs[0] != 6 || line[2].Comments[0] != "(0.20 → -0.35) Inaccuracy. Nf3 was best." {
+		t.Errorf("Expected inaccuracy annotation, got %v %v", line[2].NAGs, line[2].Comments)
+	}
+
+	alternatives := line[1].Children
+	if len(alternatives) != 2 || alternatives[1].Move != "Nf3" {
+		t.Fatalf("Expected Nf3 as a variation to Qh5, got %v", alternatives)
+	}
+	nested := alternatives[1]
+	if len(nested.Children) != 2 || nested.Children[1].Move != "d6" || nested.Children[1].Children[0].Move != "d4" {
+		t.Errorf("Expected nested variation 2... d6 3. d4")
+	}
+}
+
+func TestPgnReaderErrors(t *testing.T) {
+	inputs := []string{
+		"1. e4 ( e5",
+		"1. e4 e5 ) 2. Nf3 *",
+		"[Event \"unterminated",
+		"1. e4 { never closed",
+	}
+	for _, in := range inputs {
+		if _, err := ParsePgn(in); err == nil || err == io.EOF {
+			t.Errorf("Expected a parse error for %q", in)
+		}
+	}
+
+	r := NewPgnReader(strings.NewReader("1. e4 e5 ) *\n\n[Event \"next\"]\n\n1. d4 *"))
+	if _, err := r.Next(); err == nil {
+		t.Errorf("Expected an error for the malformed game")
+	}
+	if game, err := r.Next(); err != nil || game.Tags.Get("Event") != "next" {
+		t.Errorf("Expected the reader to resume at the next game, got %v", err)
+	}
+}
+
+func TestPgnGameConversion(t *testing.T) {
+	pg, err := ParsePgn(lichessPgn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	game := pg.Game()
+	if game.ID != "XWWk5HG6" || game.Speed != "blitz" || !game.Rated || game.Winner != "black" {
+		t.Errorf("Unexpected game fields: %+v", game)
+	}
+	if game.Players.Black.User.Title != "GM" || game.Players.White.RatingDiff != -4 || game.Players.Black.Rating != 2900 {
+		t.Errorf("Unexpected players: %+v", game.Players)
+	}
+	if game.Moves != "e4 e5 Qh5 Nc6" || len(game.Clocks) != 4 || game.Clocks[2] != 17800 {
+		t.Errorf("Unexpected moves or clocks: %s %v", game.Moves, game.Clocks)
+	}
+	if len(game.Analysis) != 4 || game.Analysis[3].Mate != -4 || game.Analysis[2].Eval != -35 {
+		t.Errorf("Unexpected analysis: %+v", game.Analysis)
+	}
+
+	back := NewPgnGame(game)
+	for _, tag := range []string{"Event", "Site", "Date", "White", "Black", "Result", "UTCTime", "WhiteElo", "BlackRatingDiff", "BlackTitle", "TimeControl", "ECO", "Opening", "Termination"} {
+		if back.Tags.Get(tag) != pg.Tags.Get(tag) {
+			t.Errorf("Tag %s did not survive conversion: %q != %q", tag, back.Tags.Get(tag), pg.Tags.Get(tag))
+		}
+	}
+	line := back.MainLine()
+	if len(line) != 4 || *line[2].Clock != 178*time.Second || line[3].Eval.Mate != -4 {
+		t.Errorf("Unexpected converted main line")
+	}
+}
+
+const scholarsMatePgn = `[Event "Rated blitz game"]
+[Result "1-0"]
+
+1. e4 { [%eval 0.3] } 1... e5 { [%eval 0.25] } 2. Bc4 { [%eval 0.2] } 2... Nc6 { [%eval 0.3] } 3. Qh5 { [%eval 0.1] } 3... Nf6 { [%eval #1] } 4. Qxf7# 1-0
+`
+
+func TestPgnGameFinalMate(t *testing.T) {
+	pg, err := ParsePgn(scholarsMatePgn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	game := pg.Game()
+	if len(game.Analysis) != 7 || game.Analysis[5].Mate != 1 || game.Analysis[6].Mate != 1 || game.Analysis[6].Eval != 0 {
+		t.Errorf("Expected the final checkmate to be scored as mate, got %+v", game.Analysis)
+	}
+
+	pg, _ = ParsePgn("1. e4 { [%eval 0.3] } 1... e5 { [%eval 0.25] } 2. Nf3 Nc6 *")
+	if game := pg.Game(); len(game.Analysis) != 2 {
+		t.Errorf("Expected the plies without eval to be left out, got %+v", game.Analysis)
+	}
+}
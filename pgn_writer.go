@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type PgnWriterOptions struct {
+	Clocks     bool
+	Evals      bool
+	Comments   bool
+	Variations bool
+	NAGs       bool
+	LineWidth  int
+}
+
+func NewPgnWriterOptions() PgnWriterOptions {
+	return PgnWriterOptions{
+		Clocks:     true,
+		Evals:      true,
+		Comments:   true,
+		Variations: true,
+		NAGs:       true,
+		LineWidth:  80,
+	}
+}
+
+type PgnWriter struct {
+	w       *bufio.Writer
+	options PgnWriterOptions
+}
+
+func NewPgnWriter(w io.Writer, options PgnWriterOptions) *PgnWriter {
+	return &PgnWriter{w: bufio.NewWriter(w), options: options}
+}
+
+func (pw *PgnWriter) Write(game *Game) error {
+	return pw.WriteGame(NewPgnGame(game))
+}
+
+func (pw *PgnWriter) WriteGame(game *PgnGame) error {
+	for _, tag := range game.Tags {
+		fmt.Fprintf(pw.w, "[%s \"%s\"]\n", tag.Name, escapePgnTag(tag.Value))
+	}
+	if len(game.Tags) > 0 {
+		pw.w.WriteString("\n")
+	}
+
+	tokens := pw.movetext(game)
+	width := 0
+	for _, tok := range tokens {
+		switch {
+		case width == 0:
+		case pw.options.LineWidth > 0 && width+1+utf8.RuneCountInString(tok) > pw.options.LineWidth:
+			pw.w.WriteString("\n")
+			width = 0
+		default:
+			pw.w.WriteString(" ")
+			width++
+		}
+		pw.w.WriteString(tok)
+		width += utf8.RuneCountInString(tok)
+	}
+	pw.w.WriteString("\n\n")
+	return pw.w.Flush()
+}
+
+func (g *PgnGame) String() string {
+	var sb strings.Builder
+	NewPgnWriter(&sb, NewPgnWriterOptions()).WriteGame(g)
+	return sb.String()
+}
+
+func escapePgnTag(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+func (pw *PgnWriter) movetext(game *PgnGame) []string {
+	var tokens []string
+	for _, c := range game.Root.Comments {
+		tokens = appendPgnComment(tokens, c)
+	}
+	tokens = pw.line(tokens, game.Root, pgnStartPly(game.Tags.Get("FEN")), true)
+	result := game.Result
+	if result == "" {
+		result = "*"
+	}
+	return append(tokens, result)
+}
+
+func pgnStartPly(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		if len(fields) >= 2 && fields[1] == "b" {
+			return 1
+		}
+		return 0
+	}
+	fullmove, err := strconv.Atoi(fields[5])
+	if err != nil || fullmove < 1 {
+		fullmove = 1
+	}
+	ply := (fullmove - 1) * 2
+	if fields[1] == "b" {
+		ply++
+	}
+	return ply
+}
+
+func (pw *PgnWriter) line(tokens []string, parent *PgnNode, ply int, forceNumber bool) []string {
+	for len(parent.Children) > 0 {
+		main := parent.Children[0]
+		tokens = pw.move(tokens, main, ply, forceNumber)
+		forceNumber = pw.hasComments(main)
+
+		if pw.options.Variations {
+			for _, alt := range parent.Children[1:] {
+				start := len(tokens)
+				tokens = pw.move(tokens, alt, ply, true)
+				tokens = pw.line(tokens, alt, ply+1, pw.hasComments(alt))
+				tokens[start] = "(" + tokens[start]
+				tokens[len(tokens)-1] += ")"
+				forceNumber = true
+			}
+		}
+
+		parent = main
+		ply++
+	}
+	return tokens
+}
+
+func (pw *PgnWriter) move(tokens []string, node *PgnNode, ply int, forceNumber bool) []string {
+	if pw.options.Comments {
+		for _, c := range node.StartingComments {
+			tokens = appendPgnComment(tokens, c)
+			forceNumber = true
+		}
+	}
+
+	number := ply/2 + 1
+	if ply%2 == 0 {
+		tokens = append(tokens, strconv.Itoa(number)+".")
+	} else if forceNumber {
+		tokens = append(tokens, strconv.Itoa(number)+"...")
+	}
+
+	san := node.Move
+	var nags []string
+	if pw.options.NAGs {
+		for _, nag := range node.NAGs {
+			glyph := pgnNagGlyph(nag)
+			if glyph != "" && !strings.ContainsAny(san, "!?") {
+				san += glyph
+			} else {
+				nags = append(nags, "$"+strconv.Itoa(nag))
+			}
+		}
+	}
+	tokens = append(tokens, san)
+	tokens = append(tokens, nags...)
+
+	if pw.options.Comments {
+		for _, c := range node.Comments {
+			tokens = appendPgnComment(tokens, c)
+		}
+	}
+	var commands []string
+	if pw.options.Evals && node.Eval != nil {
+		commands = append(commands, "[%eval "+FormatPgnEval(*node.Eval)+"]")
+	}
+	if pw.options.Clocks && node.Clock != nil {
+		commands = append(commands, "[%clk "+FormatPgnClock(*node.Clock)+"]")
+	}
+	if len(commands) > 0 {
+		tokens = appendPgnComment(tokens, strings.Join(commands, " "))
+	}
+	return tokens
+}
+
+func (pw *PgnWriter) hasComments(node *PgnNode) bool {
+	return (pw.options.Comments && len(node.Comments) > 0) ||
+		(pw.options.Evals && node.Eval != nil) ||
+		(pw.options.Clocks && node.Clock != nil)
+}
+
+func appendPgnComment(tokens []string, comment string) []string {
+	comment = strings.Replace(comment, "}", ")", -1)
+	tokens = append(tokens, "{")
+	tokens = append(tokens, strings.Fields(comment)...)
+	return append(tokens, "}")
+}
+
+func pgnNagGlyph(nag int) string {
+	for glyph, n := range pgnSuffixNags {
+		if n == nag {
+			return glyph
+		}
+	}
+	return ""
+}
+
+func FormatPgnClock(d time.Duration) string {
+	secs := int64((d + time.Second/2) / time.Second)
+	if d < 0 {
+		secs = 0
+	}
+	return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
+func FormatPgnEval(e PgnEval) string {
+	if e.Mate != 0 {
+		return "#" + strconv.Itoa(e.Mate)
+	}
+	s := strconv.FormatFloat(float64(e.CP)/100, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPgnWriterRoundTrip(t *testing.T) {
+	pg, err := ParsePgn(lichessPgn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	options := NewPgnWriterOptions()
+	options.LineWidth = 0
+	var sb strings.Builder
+	if err := NewPgnWriter(&sb, options).WriteGame(pg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "1. e4 { [%eval 0.17] [%clk 0:03:00] } 1... e5 { [%eval 0.2] [%clk 0:03:00] } " +
+		"2. Qh5?! { (0.20 → -0.35) Inaccuracy. Nf3 was best. } { [%eval -0.35] [%clk 0:02:58] } " +
+		"(2. Nf3 Nc6 (2... d6 3. d4) 3. Bb5) 2... Nc6! { [%eval #-4] [%clk 0:02:59] } 0-1\n\n"
+	out := sb.String()
+	if !strings.HasSuffix(out, expected) {
+		t.Errorf("Unexpected movetext:\n%s", out)
+	}
+	if !strings.HasPrefix(out, "[Event \"Rated Blitz game\"]\n[Site \"https://lichess.org/XWWk5HG6\"]\n") {
+		t.Errorf("Expected tag pairs in their original order:\n%s", out)
+	}
+
+	again, err := ParsePgn(out)
+	if err != nil || again.String() != pg.String() {
+		t.Errorf("Expected written PGN to parse back to the same game, got %v", err)
+	}
+}
+
+func TestPgnWriterLineWrapping(t *testing.T) {
+	game := Game{
+		ID:      "abcdefgh",
+		Rated:   true,
+		Variant: "standard",
+		Speed:   "blitz",
+		Perf:    "blitz",
+		Status:  "mate",
+		Winner:  "white",
+		Moves:   "e4 e5 Bc4 Nc6 Qh5 Nf6 Qxf7#",
+		Clocks:  []int{30003, 30003, 29810, 29750, 29101, 29400, 28002},
+	}
+	game.Players.White.User.Name = "alice"
+	game.Players.White.Rating = 1500
+	game.Players.Black.AiLevel = 3
+	game.Clock.Initial = 300
+	game.Clock.Increment = 3
+	game.Analysis = make([]MoveAnalysis, 7)
+	game.Analysis[5] = MoveAnalysis{Mate: 1, Variation: "g6 Qf3", Judgment: &Judgment{Name: "Blunder", Comment: "Checkmate is now unavoidable. g6 was best."}}
+
+	out := NewPgnGame(&game).String()
+	for _, line := range strings.Split(out, "\n") {
+		if len([]rune(line)) > 80 {
+			t.Errorf("Line longer than 80 characters: %q", line)
+		}
+	}
+	for _, want := range []string{`[Event "Rated Blitz game"]`, `[Black "lichess AI level 3"]`, `[TimeControl "300+3"]`, `[Result "1-0"]`, `[Termination "Normal"]`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in output:\n%s", want, out)
+		}
+	}
+
+	pg, err := ParsePgn(out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	line := pg.MainLine()
+	if len(line) != 7 || line[5].NAGs[0] != 4 || line[4].Children[1].Move != "g6" || *line[0].Clock != 300*time.Second {
+		t.Errorf("Wrapped PGN did not parse back into the same tree:\n%s", out)
+	}
+	back := pg.Game()
+	if back.Moves != game.Moves || back.Status != "mate" || back.Players.Black.AiLevel != 3 {
+		t.Errorf("Unexpected game after round trip: %+v", back)
+	}
+}
+
+func TestFormatPgnAnnotations(t *testing.T) {
+	if s := FormatPgnClock(3723 * time.Second); s != "1:02:03" {
+		t.Errorf("Unexpected clock format %s", s)
+	}
+	evals := map[PgnEval]string{{CP: 17}: "0.17", {CP: -150}: "-1.5", {CP: 0}: "0.0", {Mate: -3}: "#-3"}
+	for eval, want := range evals {
+		if s := FormatPgnEval(eval); s != want {
+			t.Errorf("Expected %s, got %s", want, s)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+const polyglotEntrySize = 16
+
+type PolyglotEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+type BookMove struct {
+	Move   Move
+	Weight int
+}
+
+type PolyglotBook struct {
+	Entries []PolyglotEntry
+}
+
+func ReadPolyglotBook(r io.Reader) (*PolyglotBook, error) {
+	book := &PolyglotBook{}
+	br := bufio.NewReader(r)
+	var buf [polyglotEntrySize]byte
+	for {
+		_, err := io.ReadFull(br, buf[:])
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated polyglot entry")
+		}
+		if err != nil {
+			return nil, err
+		}
+		book.Entries = append(book.Entries, PolyglotEntry{
+			Key:    binary.BigEndian.Uint64(buf[0:8]),
+			Move:   binary.BigEndian.Uint16(buf[8:10]),
+			Weight: binary.BigEndian.Uint16(buf[10:12]),
+			Learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+	sort.SliceStable(book.Entries, func(i, j int) bool {
+		return book.Entries[i].Key < book.Entries[j].Key
+	})
+	return book, nil
+}
+
+func OpenPolyglotBook(path string) (*PolyglotBook, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPolyglotBook(f)
+}
+
+func (b *PolyglotBook) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var buf [polyglotEntrySize]byte
+	var n int64
+	for _, e := range b.Entries {
+		binary.BigEndian.PutUint64(buf[0:8], e.Key)
+		binary.BigEndian.PutUint16(buf[8:10], e.Move)
+		binary.BigEndian.PutUint16(buf[10:12], e.Weight)
+		binary.BigEndian.PutUint32(buf[12:16], e.Learn)
+		written, err := bw.Write(buf[:])
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+func (b *PolyglotBook) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := b.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Lookup returns the legal book moves for the position, best weighted first.
+func (b *PolyglotBook) Lookup(p *Position) []BookMove {
+	key := p.PolyglotHash()
+	i := sort.Search(len(b.Entries), func(i int) bool {
+		return b.Entries[i].Key >= key
+	})
+
+	var moves []BookMove
+	for ; i < len(b.Entries) && b.Entries[i].Key == key; i++ {
+		m, ok := decodePolyglotMove(p, b.Entries[i].Move)
+		if ok {
+			moves = append(moves, BookMove{Move: m, Weight: int(b.Entries[i].Weight)})
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	return moves
+}
+
+// ChooseMove picks a book move at random with probability proportional to
+// its weight. It returns false when the position is not in the book.
+func (b *PolyglotBook) ChooseMove(p *Position, rnd *rand.Rand) (Move, bool) {
+	moves := b.Lookup(p)
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		if len(moves) == 0 {
+			return NullMove, false
+		}
+		return moves[0].Move, true
+	}
+
+	var n int
+	if rnd != nil {
+		n = rnd.Intn(total)
+	} else {
+		n = rand.Intn(total)
+	}
+	for _, m := range moves {
+		if n < m.Weight {
+			return m.Move, true
+		}
+		n -= m.Weight
+	}
+	return moves[len(moves)-1].Move, true
+}
+
+func encodePolyglotMove(m Move) uint16 {
+	promotion := 0
+	if m.Promotion >= Knight && m.Promotion <= Queen {
+		promotion = int(m.Promotion - Pawn)
+	}
+	return uint16(m.To.File() | m.To.Rank()<<3 | m.From.File()<<6 | m.From.Rank()<<9 | promotion<<12)
+}
+
+func decodePolyglotMove(p *Position, code uint16) (Move, bool) {
+	for _, m := range p.LegalMoves() {
+		if m.Drop == NoPieceType && encodePolyglotMove(m) == code {
+			return m, true
+		}
+	}
+	return NullMove, false
+}
+
+type PolyglotBuilderOptions struct {
+	MinRating int
+	Results   []string
+	MaxPly    int
+}
+
+func NewPolyglotBuilderOptions() PolyglotBuilderOptions {
+	return PolyglotBuilderOptions{MaxPly: 24}
+}
+
+// PolyglotBuilder accumulates moves from games into a book. Each move scores
+// 2 when played by the winner and 1 in a draw, as the Polyglot tool does.
+type PolyglotBuilder struct {
+	Options PolyglotBuilderOptions
+	Games   int
+	weights map[uint64]map[uint16]int
+}
+
+func NewPolyglotBuilder(opts PolyglotBuilderOptions) *PolyglotBuilder {
+	return &PolyglotBuilder{Options: opts, weights: make(map[uint64]map[uint16]int)}
+}
+
+func (b *PolyglotBuilder) accepts(game *Game) bool {
+	if game.Variant != "" && game.Variant != VariantStandard {
+		return false
+	}
+	if game.InitialFen != "" && game.InitialFen != StartingFen {
+		return false
+	}
+	if game.Players.White.Rating < b.Options.MinRating || game.Players.Black.Rating < b.Options.MinRating {
+		return false
+	}
+	result := GameResult(game)
+	if result == "*" {
+		return false
+	}
+	if len(b.Options.Results) == 0 {
+		return true
+	}
+	for _, r := range b.Options.Results {
+		if r == result {
+			return true
+		}
+	}
+	return false
+}
+
+// Add includes the game in the book if it passes the filters and reports
+// whether it was used.
+func (b *PolyglotBuilder) Add(game *Game) bool {
+	if !b.accepts(game) {
+		return false
+	}
+
+	pos := NewPosition()
+	moves := strings.Fields(game.Moves)
+	for i, san := range moves {
+		if b.Options.MaxPly > 0 && i >= b.Options.MaxPly {
+			break
+		}
+		m, err := pos.ParseSAN(san)
+		if err != nil {
+			break
+		}
+		score := 1
+		if game.Winner == pos.Turn.String() {
+			score = 2
+		} else if game.Winner != "" {
+			score = 0
+		}
+		if score > 0 {
+			key := pos.PolyglotHash()
+			if b.weights[key] == nil {
+				b.weights[key] = make(map[uint16]int)
+			}
+			b.weights[key][encodePolyglotMove(m)] += score
+		}
+		pos = pos.Play(m)
+	}
+	b.Games++
+	return true
+}
+
+// AddPgn adds every game read from a PGN stream, such as a Lichess export.
+func (b *PolyglotBuilder) AddPgn(r io.Reader) error {
+	reader := NewPgnReader(r)
+	for {
+		pg, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.Add(pg.Game())
+	}
+}
+
+func (b *PolyglotBuilder) Book() *PolyglotBook {
+	max := 0
+	for _, moves := range b.weights {
+		for _, w := range moves {
+			if w > max {
+				max = w
+			}
+		}
+	}
+
+	book := &PolyglotBook{}
+	for key, moves := range b.weights {
+		for move, w := range moves {
+			if max > 0xffff {
+				w = w * 0xffff / max
+				if w == 0 {
+					w = 1
+				}
+			}
+			book.Entries = append(book.Entries, PolyglotEntry{Key: key, Move: move, Weight: uint16(w)})
+		}
+	}
+	sort.Slice(book.Entries, func(i, j int) bool {
+		a, c := book.Entries[i], book.Entries[j]
+		if a.Key != c.Key {
+			return a.Key < c.Key
+		}
+		if a.Weight != c.Weight {
+			return a.Weight > c.Weight
+		}
+		return a.Move < c.Move
+	})
+	return book
+}
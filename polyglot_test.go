@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPolyglotHash(t *testing.T) {
+	tests := []struct {
+		moves string
+		hash  uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e4", 0x823c9b50fd114196},
+		{"e4 d5", 0x0756b94461c50fb0},
+		{"e4 d5 e5", 0x662fafb965db29d4},
+		{"e4 d5 e5 f5", 0x22a48b5a8e47ff78},
+		{"e4 d5 e5 f5 Ke2", 0x652a607ca3f242c1},
+		{"e4 d5 e5 f5 Ke2 Kf7", 0x00fdd303c946bdd9},
+		{"a4 b5 h4 b4 c4", 0x3c8123ea7b067637},
+		{"a4 b5 h4 b4 c4 bxc3 Ra3", 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		pos := NewPosition()
+		if test.moves != "" {
+			plies, err := Replay("", test.moves)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			pos = plies[len(plies)-1].Position
+		}
+		if h := pos.PolyglotHash(); h != test.hash {
+			t.Errorf("%q: expected %016x, got %016x", test.moves, test.hash, h)
+		}
+	}
+}
+
+func TestPolyglotMoveEncoding(t *testing.T) {
+	pos := NewPosition()
+	e4, _ := pos.ParseSAN("e4")
+	if code := encodePolyglotMove(e4); code != 796 {
+		t.Errorf("Expected e2e4 to encode as 796, got %d", code)
+	}
+
+	pos, _ = ParseFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	castle, _ := pos.ParseSAN("O-O")
+	if code := encodePolyglotMove(castle); code != 263 {
+		t.Errorf("Expected O-O to encode as e1h1 (263), got %d", code)
+	}
+	if m, ok := decodePolyglotMove(pos, 263); !ok || m != castle {
+		t.Errorf("Expected e1h1 to decode as castling, got %v", m)
+	}
+}
+
+func TestPolyglotBuilder(t *testing.T) {
+	opts := NewPolyglotBuilderOptions()
+	opts.MinRating = 2000
+	opts.MaxPly = 2
+	builder := NewPolyglotBuilder(opts)
+
+	games := mustParseGames(`
+{"moves":"e4 e5 Nf3","status":"mate","winner":"white","players":{"white":{"rating":2200},"black":{"rating":2200}}}
+{"moves":"e4 c5 Nf3","status":"mate","winner":"black","players":{"white":{"rating":2200},"black":{"rating":2200}}}
+{"moves":"d4 d5 c4","status":"draw","players":{"white":{"rating":2100},"black":{"rating":2100}}}
+{"moves":"b4 e5","status":"mate","winner":"white","players":{"white":{"rating":1500},"black":{"rating":1500}}}
+`)
+	added := 0
+	for i := range games {
+		if builder.Add(&games[i]) {
+			added++
+		}
+	}
+	if added != 3 || builder.Games != 3 {
+		t.Fatalf("Expected 3 games to pass the filters, got %d", added)
+	}
+
+	var buf bytes.Buffer
+	if _, err := builder.Book().WriteTo(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	book, err := ReadPolyglotBook(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(book.Entries) != 4 {
+		t.Errorf("Expected 4 entries, got %d", len(book.Entries))
+	}
+
+	moves := book.Lookup(NewPosition())
+	if len(moves) != 2 || moves[0].Move.String() != "e2e4" || moves[0].Weight != 2 || moves[1].Move.String() != "d2d4" || moves[1].Weight != 1 {
+		t.Errorf("Unexpected book moves %+v", moves)
+	}
+
+	plies, _ := Replay("", "e4")
+	replies := book.Lookup(plies[0].Position)
+	if len(replies) != 1 || replies[0].Move.String() != "c7c5" {
+		t.Errorf("Expected only the winning reply c5, got %+v", replies)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		m, ok := book.ChooseMove(NewPosition(), rnd)
+		if !ok {
+			t.Fatalf("Expected a book move")
+		}
+		counts[m.String()]++
+	}
+	if counts["e2e4"] < 150 || counts["d2d4"] < 50 {
+		t.Errorf("Expected a 2:1 weighted choice, got %v", counts)
+	}
+	if _, ok := book.ChooseMove(plies[0].Position.Play(replies[0].Move), rnd); ok {
+		t.Errorf("Expected no move beyond the ply limit")
+	}
+}
+
+func TestPolyglotResultFilter(t *testing.T) {
+	opts := NewPolyglotBuilderOptions()
+	opts.Results = []string{"1/2-1/2"}
+	builder := NewPolyglotBuilder(opts)
+	err := builder.AddPgn(strings.NewReader(`[Result "1-0"]
+
+1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0
+
+[Result "1/2-1/2"]
+
+1. d4 d5 1/2-1/2
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if builder.Games != 1 {
+		t.Errorf("Expected only the draw to be added, got %d games", builder.Games)
+	}
+	if moves := builder.Book().Lookup(NewPosition()); len(moves) != 1 || moves[0].Move.String() != "d2d4" {
+		t.Errorf("Unexpected book moves %+v", moves)
+	}
+}
+
+func TestReadPolyglotBookTruncated(t *testing.T) {
+	if _, err := ReadPolyglotBook(bytes.NewReader(make([]byte, 20))); err == nil {
+		t.Errorf("Expected an error for a truncated book")
+	}
+}
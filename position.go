@@ -0,0 +1,449 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const StartingFen = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+type Color int8
+
+const (
+	White Color = iota
+	Black
+)
+
+func (c Color) Other() Color {
+	return c ^ 1
+}
+
+func (c Color) String() string {
+	if c == White {
+		return "white"
+	}
+	return "black"
+}
+
+type PieceType int8
+
+const (
+	NoPieceType PieceType = iota
+	Pawn
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+const pieceLetters = " pnbrqk"
+
+func (pt PieceType) String() string {
+	return strings.ToUpper(string(pieceLetters[pt]))
+}
+
+func pieceTypeFromLetter(r byte) PieceType {
+	i := strings.IndexByte(pieceLetters, byte(strings.ToLower(string(r))[0]))
+	if i <= 0 {
+		return NoPieceType
+	}
+	return PieceType(i)
+}
+
+type Piece struct {
+	Type  PieceType
+	Color Color
+}
+
+var NoPiece = Piece{}
+
+func (p Piece) String() string {
+	if p.Type == NoPieceType {
+		return ""
+	}
+	if p.Color == White {
+		return p.Type.String()
+	}
+	return strings.ToLower(p.Type.String())
+}
+
+type Square int8
+
+const NoSquare Square = -1
+
+func NewSquare(file, rank int) Square {
+	if file < 0 || file > 7 || rank < 0 || rank > 7 {
+		return NoSquare
+	}
+	return Square(rank*8 + file)
+}
+
+func ParseSquare(s string) (Square, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return NoSquare, fmt.Errorf("invalid square %q", s)
+	}
+	return NewSquare(int(s[0]-'a'), int(s[1]-'1')), nil
+}
+
+func (sq Square) File() int {
+	return int(sq) % 8
+}
+
+func (sq Square) Rank() int {
+	return int(sq) / 8
+}
+
+func (sq Square) String() string {
+	if sq < 0 || sq > 63 {
+		return "-"
+	}
+	return string(rune('a'+sq.File())) + string(rune('1'+sq.Rank()))
+}
+
+const (
+	KingSide  = 0
+	QueenSide = 1
+)
+
+type Position struct {
+	Board          [64]Piece
+	Turn           Color
+	CastlingRooks  [2][2]Square
+	EpSquare       Square
+	HalfmoveClock  int
+	FullmoveNumber int
+	Variant        string
+	Pockets        [2][7]int
+	Promoted       [64]bool
+	ChecksGiven    [2]int
+}
+
+func NewPosition() *Position {
+	pos, _ := ParseFen(StartingFen)
+	return pos
+}
+
+func emptyPosition() *Position {
+	pos := &Position{EpSquare: NoSquare, FullmoveNumber: 1, Variant: VariantStandard}
+	for c := range pos.CastlingRooks {
+		pos.CastlingRooks[c] = [2]Square{NoSquare, NoSquare}
+	}
+	return pos
+}
+
+func (p *Position) Copy() *Position {
+	cp := *p
+	return &cp
+}
+
+func (p *Position) KingSquare(c Color) Square {
+	for sq, pc := range p.Board {
+		if pc.Type == King && pc.Color == c {
+			return Square(sq)
+		}
+	}
+	return NoSquare
+}
+
+func (p *Position) Ply() int {
+	ply := (p.FullmoveNumber - 1) * 2
+	if p.Turn == Black {
+		ply++
+	}
+	return ply
+}
+
+func ParseFen(fen string) (*Position, error) {
+	return ParseVariantFen(VariantStandard, fen)
+}
+
+func ParseVariantFen(variant, fen string) (*Position, error) {
+	if _, ok := variantStartingFens[variant]; !ok && variant != "" {
+		return nil, fmt.Errorf("unsupported variant %q", variant)
+	}
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid FEN %q: expected at least 4 fields", fen)
+	}
+	pos := emptyPosition()
+	if variant != "" {
+		pos.Variant = variant
+	}
+
+	board := fields[0]
+	if i := strings.IndexByte(board, '['); i >= 0 && strings.HasSuffix(board, "]") {
+		if err := pos.parsePockets(board[i+1 : len(board)-1]); err != nil {
+			return nil, fmt.Errorf("invalid FEN %q: %v", fen, err)
+		}
+		board = board[:i]
+	}
+	ranks := strings.Split(board, "/")
+	if len(ranks) == 9 {
+		if err := pos.parsePockets(ranks[8]); err != nil {
+			return nil, fmt.Errorf("invalid FEN %q: %v", fen, err)
+		}
+		ranks = ranks[:8]
+	}
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: expected 8 ranks", fen)
+	}
+	for i, row := range ranks {
+		rank := 7 - i
+		file := 0
+		for j := 0; j < len(row); j++ {
+			ch := row[j]
+			switch {
+			case ch >= '1' && ch <= '8':
+				file += int(ch - '0')
+			case ch == '~' && file > 0:
+				pos.Promoted[NewSquare(file-1, rank)] = true
+			default:
+				pt := pieceTypeFromLetter(ch)
+				if pt == NoPieceType || file > 7 {
+					return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, row)
+				}
+				color := White
+				if ch >= 'a' {
+					color = Black
+				}
+				pos.Board[NewSquare(file, rank)] = Piece{Type: pt, Color: color}
+				file++
+			}
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, row)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		pos.Turn = White
+	case "b":
+		pos.Turn = Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: bad side to move", fen)
+	}
+
+	if pos.Variant != VariantAntichess {
+		if err := pos.parseCastling(fields[2]); err != nil {
+			return nil, fmt.Errorf("invalid FEN %q: %v", fen, err)
+		}
+	}
+
+	if fields[3] != "-" {
+		sq, err := ParseSquare(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEN %q: %v", fen, err)
+		}
+		pos.EpSquare = sq
+	}
+
+	rest := fields[4:]
+	if pos.Variant == VariantThreeCheck {
+		pos.ChecksGiven = [2]int{}
+		if len(rest) > 0 && strings.Contains(rest[0], "+") {
+			if err := pos.parseRemainingChecks(rest[0]); err != nil {
+				return nil, fmt.Errorf("invalid FEN %q: %v", fen, err)
+			}
+			rest = rest[1:]
+		}
+		if len(rest) > 0 && strings.HasPrefix(rest[len(rest)-1], "+") {
+			var white, black int
+			if _, err := fmt.Sscanf(rest[len(rest)-1], "+%d+%d", &white, &black); err != nil {
+				return nil, fmt.Errorf("invalid FEN %q: bad check counts", fen)
+			}
+			pos.ChecksGiven = [2]int{white, black}
+			rest = rest[:len(rest)-1]
+		}
+	}
+
+	if len(rest) > 0 {
+		n, err := strconv.Atoi(rest[0])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid FEN %q: bad halfmove clock", fen)
+		}
+		pos.HalfmoveClock = n
+	}
+	if len(rest) > 1 {
+		n, err := strconv.Atoi(rest[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid FEN %q: bad fullmove number", fen)
+		}
+		pos.FullmoveNumber = n
+	}
+
+	for _, c := range []Color{White, Black} {
+		if pos.Variant == VariantAntichess || (pos.Variant == VariantHorde && c == White) {
+			continue
+		}
+		if pos.KingSquare(c) == NoSquare {
+			return nil, fmt.Errorf("invalid FEN %q: missing %s king", fen, c)
+		}
+	}
+	return pos, nil
+}
+
+func (p *Position) parsePockets(s string) error {
+	for i := 0; i < len(s); i++ {
+		pt := pieceTypeFromLetter(s[i])
+		if pt == NoPieceType || pt == King {
+			return fmt.Errorf("bad pocket %q", s)
+		}
+		color := White
+		if s[i] >= 'a' {
+			color = Black
+		}
+		p.Pockets[color][pt]++
+	}
+	return nil
+}
+
+func (p *Position) parseRemainingChecks(s string) error {
+	var white, black int
+	if _, err := fmt.Sscanf(s, "%d+%d", &white, &black); err != nil || white < 0 || white > 3 || black < 0 || black > 3 {
+		return fmt.Errorf("bad remaining checks %q", s)
+	}
+	p.ChecksGiven = [2]int{3 - white, 3 - black}
+	return nil
+}
+
+func (p *Position) parseCastling(s string) error {
+	if s == "-" {
+		return nil
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		color := White
+		if ch >= 'a' {
+			color = Black
+		}
+		rank := 0
+		if color == Black {
+			rank = 7
+		}
+		king := p.KingSquare(color)
+		if king == NoSquare || king.Rank() != rank {
+			return fmt.Errorf("castling right %q without a king on the back rank", ch)
+		}
+
+		rook := NoSquare
+		switch lower := ch | 0x20; {
+		case lower == 'k':
+			for f := 7; f > king.File(); f-- {
+				if pc := p.Board[NewSquare(f, rank)]; pc.Type == Rook && pc.Color == color {
+					rook = NewSquare(f, rank)
+					break
+				}
+			}
+		case lower == 'q':
+			for f := 0; f < king.File(); f++ {
+				if pc := p.Board[NewSquare(f, rank)]; pc.Type == Rook && pc.Color == color {
+					rook = NewSquare(f, rank)
+					break
+				}
+			}
+		case lower >= 'a' && lower <= 'h':
+			rook = NewSquare(int(lower-'a'), rank)
+		default:
+			return fmt.Errorf("bad castling field %q", s)
+		}
+		if rook == NoSquare || p.Board[rook] != (Piece{Type: Rook, Color: color}) {
+			return fmt.Errorf("castling right %q without a rook", ch)
+		}
+
+		side := KingSide
+		if rook.File() < king.File() {
+			side = QueenSide
+		}
+		p.CastlingRooks[color][side] = rook
+	}
+	return nil
+}
+
+func (p *Position) Fen() string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			pc := p.Board[NewSquare(file, rank)]
+			if pc.Type == NoPieceType {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteString(pc.String())
+			if p.Variant == VariantCrazyhouse && p.Promoted[NewSquare(file, rank)] {
+				sb.WriteByte('~')
+			}
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	turn := "w"
+	if p.Turn == Black {
+		turn = "b"
+	}
+	ep := NoSquare
+	if p.hasEpCapture() {
+		ep = p.EpSquare
+	}
+	if p.Variant == VariantCrazyhouse {
+		sb.WriteString("[" + p.pocketString() + "]")
+	}
+	checks := ""
+	if p.Variant == VariantThreeCheck {
+		checks = fmt.Sprintf(" %d+%d", 3-p.ChecksGiven[White], 3-p.ChecksGiven[Black])
+	}
+	return fmt.Sprintf("%s %s %s %s%s %d %d", sb.String(), turn, p.castlingString(), ep, checks, p.HalfmoveClock, p.FullmoveNumber)
+}
+
+func (p *Position) castlingString() string {
+	var sb strings.Builder
+	for _, c := range []Color{White, Black} {
+		for _, side := range []int{KingSide, QueenSide} {
+			rook := p.CastlingRooks[c][side]
+			if rook == NoSquare {
+				continue
+			}
+			letter := byte('a' + rook.File())
+			if p.isOutermostRook(c, side, rook) {
+				letter = "kq"[side]
+			}
+			if c == White {
+				letter -= 0x20
+			}
+			sb.WriteByte(letter)
+		}
+	}
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
+
+func (p *Position) isOutermostRook(c Color, side int, rook Square) bool {
+	step := 1
+	if side == QueenSide {
+		step = -1
+	}
+	for f := rook.File() + step; f >= 0 && f < 8; f += step {
+		if pc := p.Board[NewSquare(f, rook.Rank())]; pc.Type == Rook && pc.Color == c {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Position) String() string {
+	return p.Fen()
+}
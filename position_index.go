@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+type PositionOccurrence struct {
+	GameID   string
+	Ply      int
+	NextMove string
+	Result   string
+}
+
+type MoveStats struct {
+	SAN       string
+	Games     int
+	WhiteWins int
+	Draws     int
+	BlackWins int
+}
+
+func (s *MoveStats) add(result string) {
+	s.Games++
+	switch result {
+	case "1-0":
+		s.WhiteWins++
+	case "0-1":
+		s.BlackWins++
+	case "1/2-1/2":
+		s.Draws++
+	}
+}
+
+type PositionStats struct {
+	MoveStats
+	Moves []MoveStats
+}
+
+// PositionIndex maps positions reached in games to where they occurred. A
+// position is recorded once per game, at the first ply it was reached, so
+// transpositions and repetitions do not count a game twice.
+type PositionIndex struct {
+	Games      int
+	byHash     map[uint64][]PositionOccurrence
+	byPawns    map[uint64][]PositionOccurrence
+	byMaterial map[string][]PositionOccurrence
+}
+
+func NewPositionIndex() *PositionIndex {
+	return &PositionIndex{
+		byHash:     make(map[uint64][]PositionOccurrence),
+		byPawns:    make(map[uint64][]PositionOccurrence),
+		byMaterial: make(map[string][]PositionOccurrence),
+	}
+}
+
+func variantSalt(variant string) uint64 {
+	switch variant {
+	case "", VariantStandard, VariantChess960, VariantFromPosition:
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(variant))
+	return h.Sum64()
+}
+
+// PawnStructureHash identifies the placement of the pawns of both sides.
+func (p *Position) PawnStructureHash() uint64 {
+	var h uint64
+	for i, pc := range p.Board {
+		if pc.Type == Pawn {
+			h ^= polyglotPieceKey(pc, Square(i))
+		}
+	}
+	return h ^ variantSalt(p.Variant)
+}
+
+// MaterialSignature describes the material on the board, such as "KRPPvKR".
+func (p *Position) MaterialSignature() string {
+	var sb strings.Builder
+	for _, c := range []Color{White, Black} {
+		if c == Black {
+			sb.WriteByte('v')
+		}
+		for pt := King; pt >= Pawn; pt-- {
+			for _, pc := range p.Board {
+				if pc.Type == pt && pc.Color == c {
+					sb.WriteString(pt.String())
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (idx *PositionIndex) Add(game *Game) error {
+	replayed, err := ReplayGame(game)
+	if replayed == nil {
+		return err
+	}
+	result := GameResult(game)
+	salt := variantSalt(replayed.Initial.Variant)
+
+	seenHash := make(map[uint64]bool)
+	seenPawns := make(map[uint64]bool)
+	seenMaterial := make(map[string]bool)
+	record := func(ply int, pos *Position, hash uint64) {
+		occ := PositionOccurrence{GameID: game.ID, Ply: ply, Result: result}
+		if ply < len(replayed.Plies) {
+			occ.NextMove = replayed.Plies[ply].SAN
+		}
+		if hash ^= salt; !seenHash[hash] {
+			seenHash[hash] = true
+			idx.byHash[hash] = append(idx.byHash[hash], occ)
+		}
+		if pawns := pos.PawnStructureHash(); !seenPawns[pawns] {
+			seenPawns[pawns] = true
+			idx.byPawns[pawns] = append(idx.byPawns[pawns], occ)
+		}
+		if material := materialKey(pos.Variant, pos.MaterialSignature()); !seenMaterial[material] {
+			seenMaterial[material] = true
+			idx.byMaterial[material] = append(idx.byMaterial[material], occ)
+		}
+	}
+
+	record(0, replayed.Initial, replayed.Initial.Hash())
+	for i, ply := range replayed.Plies {
+		record(i+1, ply.Position, ply.Hash)
+	}
+	idx.Games++
+	return err
+}
+
+func (idx *PositionIndex) Lookup(p *Position) []PositionOccurrence {
+	return idx.byHash[p.Hash()^variantSalt(p.Variant)]
+}
+
+// LookupFen looks up a position given as a FEN of the variant. An empty
+// variant is standard chess.
+func (idx *PositionIndex) LookupFen(variant, fen string) ([]PositionOccurrence, error) {
+	pos, err := ParseVariantFen(variant, fen)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Lookup(pos), nil
+}
+
+func (idx *PositionIndex) LookupPawnStructure(p *Position) []PositionOccurrence {
+	return idx.byPawns[p.PawnStructureHash()]
+}
+
+func materialKey(variant, signature string) string {
+	if variant == "" || variant == VariantChess960 || variant == VariantFromPosition {
+		variant = VariantStandard
+	}
+	return variant + ":" + signature
+}
+
+func (idx *PositionIndex) LookupMaterial(variant, signature string) []PositionOccurrence {
+	return idx.byMaterial[materialKey(variant, signature)]
+}
+
+// PositionStatsOf aggregates the results of the games and of each move played next,
+// most popular move first.
+func PositionStatsOf(occurrences []PositionOccurrence) PositionStats {
+	var stats PositionStats
+	moves := make(map[string]*MoveStats)
+	for _, occ := range occurrences {
+		stats.add(occ.Result)
+		if occ.NextMove == "" {
+			continue
+		}
+		m := moves[occ.NextMove]
+		if m == nil {
+			m = &MoveStats{SAN: occ.NextMove}
+			moves[occ.NextMove] = m
+		}
+		m.add(occ.Result)
+	}
+	for _, m := range moves {
+		stats.Moves = append(stats.Moves, *m)
+	}
+	sort.Slice(stats.Moves, func(i, j int) bool {
+		if stats.Moves[i].Games != stats.Moves[j].Games {
+			return stats.Moves[i].Games > stats.Moves[j].Games
+		}
+		return stats.Moves[i].SAN < stats.Moves[j].SAN
+	})
+	return stats
+}
+
+// PositionIndexError lists the games of an archive that could not be
+// replayed. They are indexed up to the move that failed.
+type PositionIndexError struct {
+	GameIDs []string
+	Errs    []error
+}
+
+func (e *PositionIndexError) Error() string {
+	failed := make([]string, len(e.GameIDs))
+	for i, id := range e.GameIDs {
+		failed[i] = fmt.Sprintf("%s: %v", id, e.Errs[i])
+	}
+	return fmt.Sprintf("%d games could not be indexed: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// PositionIndex indexes the archived games of the user. Games that fail to
+// replay are reported in a *PositionIndexError along with the index.
+func (a *Archive) PositionIndex(username string) (*PositionIndex, error) {
+	idx := NewPositionIndex()
+	failed := &PositionIndexError{}
+	err := a.Each(username, func(game *Game) error {
+		if err := idx.Add(game); err != nil {
+			failed.GameIDs = append(failed.GameIDs, game.ID)
+			failed.Errs = append(failed.Errs, err)
+		}
+		return nil
+	})
+	if err == nil && len(failed.GameIDs) > 0 {
+		err = failed
+	}
+	return idx, err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPositionIndex(t *testing.T) {
+	idx := NewPositionIndex()
+	games := []Game{
+		{ID: "g1", Winner: "white", Status: "mate", Moves: "d4 Nf6 c4 e6 Nc3 Bb4 e3 O-O"},
+		{ID: "g2", Winner: "black", Status: "resign", Moves: "c4 e6 Nc3 Nf6 d4 Bb4 Qc2 d5"},
+		{ID: "g3", Status: "draw", Moves: "d4 Nf6 c4 e6 Nc3 Bb4 e3 c5"},
+		{ID: "g4", Status: "draw", Moves: "e4 e5 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6"},
+		{ID: "g5", Variant: VariantAtomic, Winner: "white", Status: "variantEnd", Moves: "d4 Nf6 c4 e6 Nc3 Bb4"},
+		{ID: "g6", Variant: VariantCrazyhouse, Winner: "black", Status: "resign", Moves: "e4 d5 exd5 Qxd5"},
+	}
+	for i := range games {
+		if err := idx.Add(&games[i]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	nimzo := "rnbqk2r/pppp1ppp/4pn2/8/1bPP4/2N5/PP2PPPP/R1BQKBNR w KQkq - 2 4"
+	if occ, err := idx.LookupFen(VariantAtomic, nimzo); err != nil || len(occ) != 1 || occ[0].GameID != "g5" {
+		t.Errorf("Expected the atomic game only, got %+v (%v)", occ, err)
+	}
+	if occ, err := idx.LookupFen(VariantCrazyhouse, "rnb1kbnr/ppp1pppp/8/3q4/8/8/PPPP1PPP/RNBQKBNR[Pp] w KQkq - 0 3"); err != nil || len(occ) != 1 || occ[0].GameID != "g6" {
+		t.Errorf("Expected the crazyhouse game, got %+v (%v)", occ, err)
+	}
+	occ, err := idx.LookupFen("", nimzo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(occ) != 3 || occ[0].GameID != "g1" || occ[0].Ply != 6 || occ[0].NextMove != "e3" || occ[1].NextMove != "Qc2" {
+		t.Errorf("Expected the Nimzo-Indian in 3 standard games, got %+v", occ)
+	}
+
+	stats := PositionStatsOf(occ)
+	if stats.Games != 3 || stats.WhiteWins != 1 || stats.BlackWins != 1 || stats.Draws != 1 {
+		t.Errorf("Unexpected stats %+v", stats.MoveStats)
+	}
+	if len(stats.Moves) != 2 || stats.Moves[0].SAN != "e3" || stats.Moves[0].Games != 2 || stats.Moves[0].Draws != 1 {
+		t.Errorf("Unexpected move stats %+v", stats.Moves)
+	}
+
+	plies, _ := Replay("", "e4 e5 Nf3 Nf6")
+	if occ := idx.Lookup(plies[3].Position); len(occ) != 1 || occ[0].Ply != 4 || occ[0].NextMove != "Ng1" {
+		t.Errorf("Expected a repeated position to be recorded once, got %+v", occ)
+	}
+
+	plies, _ = Replay("", "Nf3 Nf6 d4 e6 c4")
+	if occ := idx.LookupPawnStructure(plies[4].Position); len(occ) != 3 || occ[0].Ply != 4 || occ[1].Ply != 5 {
+		t.Errorf("Expected the pawn structure in 3 games, got %+v", occ)
+	}
+
+	if sig := NewPosition().MaterialSignature(); sig != "KQRRBBNNPPPPPPPPvKQRRBBNNPPPPPPPP" {
+		t.Errorf("Unexpected material signature %s", sig)
+	}
+	if occ := idx.LookupMaterial(VariantAtomic, "KQRRBBNNPPPPPPPPvKQRRBBNNPPPPPPPP"); len(occ) != 1 || occ[0].GameID != "g5" {
+		t.Errorf("Expected the atomic game only, got %+v", occ)
+	}
+}
+
+func TestArchivePositionIndex(t *testing.T) {
+	archive, err := NewArchive(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data := `{"id":"good","moves":"e4 e5 Nf3"}
+{"id":"bad","moves":"e4 e5 Ke3"}
+`
+	path, err := archive.Path("alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	idx, err := archive.PositionIndex("alice")
+	indexErr, ok := err.(*PositionIndexError)
+	if !ok || len(indexErr.GameIDs) != 1 || indexErr.GameIDs[0] != "bad" {
+		t.Fatalf("Expected the bad game to be reported, got %v", err)
+	}
+	if idx.Games != 2 {
+		t.Errorf("Expected both games to be indexed, got %d", idx.Games)
+	}
+	plies, _ := Replay("", "e4 e5")
+	if occ := idx.Lookup(plies[1].Position); len(occ) != 2 {
+		t.Errorf("Expected the bad game to be indexed up to the illegal move, got %+v", occ)
+	}
+}
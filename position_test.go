@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var perftPositions = []struct {
+	fen   string
+	nodes []int
+}{
+	{StartingFen, []int{20, 400, 8902, 197281}},
+	{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int{48, 2039, 97862}},
+	{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812, 43238}},
+	{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467}},
+	{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379}},
+	{"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", []int{46, 2079, 89890}},
+	{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []int{21, 528, 12189}},
+}
+
+func TestPerft(t *testing.T) {
+	for _, tc := range perftPositions {
+		pos, err := ParseFen(tc.fen)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %v", tc.fen, err)
+		}
+		for i, expected := range tc.nodes {
+			if nodes := pos.Perft(i + 1); nodes != expected {
+				t.Errorf("Perft(%d) of %s: expected %d, got %d", i+1, tc.fen, expected, nodes)
+			}
+		}
+	}
+}
+
+func TestFenRoundTrip(t *testing.T) {
+	fens := []string{
+		StartingFen,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	}
+	for _, fen := range fens {
+		pos, err := ParseFen(fen)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if pos.Fen() != strings.Replace(fen, "HFhf", "KQkq", 1) {
+			t.Errorf("Expected %s, got %s", fen, pos.Fen())
+		}
+	}
+
+	pos := NewPosition().Play(Move{From: NewSquare(4, 1), To: NewSquare(4, 3)})
+	if pos.Fen() != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1" {
+		t.Errorf("Expected no en passant square without a capturing pawn, got %s", pos.Fen())
+	}
+
+	for _, bad := range []string{"", "8/8/8/8/8/8/8/8 w - - 0 1", "rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "4k3/8/8/8/8/8/8/4K3 x - - 0 1"} {
+		if _, err := ParseFen(bad); err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestSanAndUci(t *testing.T) {
+	pos := NewPosition()
+	var sans []string
+	for _, uci := range strings.Fields("e2e4 e7e5 g1f3 b8c6 f1b5 g8f6 e1g1 f6e4 f1e1 e4d6 f3e5 c6e5 e1e5 f8e7 b5f1 e8g8 d2d4 e7f6 e5e1 f8e8 c2c4 e8e1 d1e1 d6c4") {
+		m, err := pos.ParseUCI(uci)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		san := pos.SAN(m)
+		sans = append(sans, san)
+		back, err := pos.ParseSAN(san)
+		if err != nil || back != m {
+			t.Errorf("SAN %s did not parse back to %s: %v", san, uci, err)
+		}
+		if pos.UCI(m) != uci {
+			t.Errorf("Expected UCI %s, got %s", uci, pos.UCI(m))
+		}
+		pos = pos.Play(m)
+	}
+	expected := "e4 e5 Nf3 Nc6 Bb5 Nf6 O-O Nxe4 Re1 Nd6 Nxe5 Nxe5 Rxe5+ Be7 Bf1 O-O d4 Bf6 Re1 Re8 c4 Rxe1 Qxe1 Nxc4"
+	if strings.Join(sans, " ") != expected {
+		t.Errorf("Unexpected SAN sequence %s", strings.Join(sans, " "))
+	}
+
+	pos, _ = ParseFen("R7/8/7k/8/8/8/8/R5K1 w - - 0 1")
+	m, _ := pos.ParseUCI("a1a7")
+	if san := pos.SAN(m); san != "R1a7" {
+		t.Errorf("Expected rank disambiguation, got %s", san)
+	}
+	if _, err := pos.ParseSAN("Ra7"); err == nil {
+		t.Errorf("Expected an error for an ambiguous move")
+	}
+	if _, err := pos.ParseSAN("Rb2"); err == nil {
+		t.Errorf("Expected an error for an illegal move")
+	}
+	pos, _ = ParseFen("6k1/8/8/8/8/8/5PPP/1r4K1 w - - 0 1")
+	if !pos.IsCheckmate() || pos.IsStalemate() {
+		t.Errorf("Expected back rank checkmate")
+	}
+	pos, _ = ParseFen("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	m, _ = pos.ParseSAN("Ra8")
+	if pos.SAN(m) != "Ra8#" || !pos.Play(m).IsCheckmate() {
+		t.Errorf("Expected Ra8 to be checkmate")
+	}
+	pos, _ = ParseFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	if !pos.IsStalemate() {
+		t.Errorf("Expected stalemate")
+	}
+	pos, _ = ParseFen("8/P6k/8/8/8/8/8/K7 w - - 0 1")
+	if m, err := pos.ParseSAN("a8=N"); err != nil || m.Promotion != Knight {
+		t.Errorf("Expected a knight promotion, got %v", err)
+	}
+}
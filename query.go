@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const queryDateLayout = "2006-01-02"
+
+type IntRange struct {
+	Min, Max int
+}
+
+func (r IntRange) Contains(n int) bool {
+	return (r.Min == 0 || n >= r.Min) && (r.Max == 0 || n <= r.Max)
+}
+
+func (r IntRange) empty() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// Query filters archived games. Player sets the point of view used by Color,
+// Rating, OpponentRating and the win/loss results; string lists match any of
+// their values.
+type Query struct {
+	Player         string
+	Opponent       string
+	Color          string
+	Rating         IntRange
+	OpponentRating IntRange
+	Eco            []string
+	Opening        string
+	Results        []string
+	Speeds         []string
+	Variants       []string
+	Statuses       []string
+	Rated          string
+	Since          time.Time
+	Until          time.Time
+}
+
+// ParseQuery parses space separated key:value terms, for example
+//
+//	player:alice color:white rating:1800..2100 eco:B20..B99 result:win date:2023-01-01..
+//
+// Values containing spaces can be quoted and lists are separated by commas.
+// Words without a key are matched against the opening name.
+func ParseQuery(s string) (*Query, error) {
+	terms, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	var words []string
+	for _, term := range terms {
+		i := strings.IndexByte(term, ':')
+		if i < 0 {
+			words = append(words, term)
+			continue
+		}
+		key, value := strings.ToLower(term[:i]), term[i+1:]
+		if value == "" {
+			return nil, fmt.Errorf("missing value for %q", key)
+		}
+		switch key {
+		case "player", "user":
+			q.Player = value
+		case "opponent", "vs":
+			q.Opponent = value
+		case "color":
+			value = strings.ToLower(value)
+			if value != "white" && value != "black" {
+				return nil, fmt.Errorf("invalid color %q", value)
+			}
+			q.Color = value
+		case "rating", "elo":
+			if q.Rating, err = parseIntRange(value); err != nil {
+				return nil, err
+			}
+		case "opponentrating", "oppelo":
+			if q.OpponentRating, err = parseIntRange(value); err != nil {
+				return nil, err
+			}
+		case "eco":
+			q.Eco = append(q.Eco, strings.Split(strings.ToUpper(value), ",")...)
+		case "opening":
+			q.Opening = value
+		case "result":
+			for _, r := range strings.Split(strings.ToLower(value), ",") {
+				switch r {
+				case "win", "loss", "draw", "white", "black", "1-0", "0-1", "1/2-1/2":
+				default:
+					return nil, fmt.Errorf("invalid result %q", r)
+				}
+				q.Results = append(q.Results, r)
+			}
+		case "speed":
+			q.Speeds = append(q.Speeds, strings.Split(value, ",")...)
+		case "variant":
+			q.Variants = append(q.Variants, strings.Split(value, ",")...)
+		case "status", "termination":
+			q.Statuses = append(q.Statuses, strings.Split(value, ",")...)
+		case "rated":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid rated value %q", value)
+			}
+			q.Rated = strings.ToLower(value)
+		case "date":
+			if q.Since, q.Until, err = parseDateRange(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown search key %q", key)
+		}
+	}
+	if len(words) > 0 {
+		q.Opening = strings.TrimSpace(q.Opening + " " + strings.Join(words, " "))
+	}
+	if q.Player == "" && (q.Color != "" || !q.Rating.empty() || !q.OpponentRating.empty()) {
+		return nil, fmt.Errorf("color and rating terms need a player")
+	}
+	for _, r := range q.Results {
+		if q.Player == "" && (r == "win" || r == "loss") {
+			return nil, fmt.Errorf("result %q needs a player", r)
+		}
+	}
+	return q, nil
+}
+
+func splitQuery(s string) ([]string, error) {
+	var terms []string
+	var sb strings.Builder
+	quoted, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if started {
+				terms = append(terms, sb.String())
+				sb.Reset()
+				started = false
+			}
+		default:
+			sb.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if started {
+		terms = append(terms, sb.String())
+	}
+	return terms, nil
+}
+
+func parseIntRange(s string) (IntRange, error) {
+	var r IntRange
+	lo, hi := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		lo, hi = s[:i], s[i+2:]
+	}
+	var err error
+	if lo != "" {
+		if r.Min, err = strconv.Atoi(lo); err != nil {
+			return r, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	if hi != "" {
+		if r.Max, err = strconv.Atoi(hi); err != nil {
+			return r, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	return r, nil
+}
+
+func parseDateRange(s string) (time.Time, time.Time, error) {
+	var since, until time.Time
+	lo, hi := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		lo, hi = s[:i], s[i+2:]
+	}
+	var err error
+	if lo != "" {
+		if since, err = time.Parse(queryDateLayout, lo); err != nil {
+			return since, until, fmt.Errorf("invalid date %q", lo)
+		}
+	}
+	if hi != "" {
+		if until, err = time.Parse(queryDateLayout, hi); err != nil {
+			return since, until, fmt.Errorf("invalid date %q", hi)
+		}
+		until = until.AddDate(0, 0, 1)
+	}
+	return since, until, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUser reports whether the player is the user, given by name or ID.
+func isUser(p GamePlayer, user string) bool {
+	return strings.EqualFold(p.User.Name, user) || strings.EqualFold(p.User.ID, user)
+}
+
+// playerColor returns the color the user, given by name or ID, played in
+// the game, or false if the user did not play in it.
+func playerColor(game *Game, user string) (Color, bool) {
+	switch {
+	case isUser(game.Players.White, user):
+		return White, true
+	case isUser(game.Players.Black, user):
+		return Black, true
+	}
+	return White, false
+}
+
+// sides returns the player's and the opponent's side of the game, or false
+// if the query player did not play in it.
+func (q *Query) sides(game *Game) (string, GamePlayer, GamePlayer, bool) {
+	white, black := game.Players.White, game.Players.Black
+	if q.Player == "" {
+		return "", white, black, true
+	}
+	color, ok := playerColor(game, q.Player)
+	switch {
+	case !ok:
+		return "", white, black, false
+	case color == Black:
+		return color.String(), black, white, true
+	}
+	return color.String(), white, black, true
+}
+
+func (q *Query) Match(game *Game) bool {
+	color, player, opponent, ok := q.sides(game)
+	if !ok {
+		return false
+	}
+	if q.Opponent != "" {
+		if q.Player != "" {
+			if !isUser(opponent, q.Opponent) {
+				return false
+			}
+		} else if !isUser(game.Players.White, q.Opponent) && !isUser(game.Players.Black, q.Opponent) {
+			return false
+		}
+	}
+	if q.Color != "" && q.Color != color {
+		return false
+	}
+	if !q.Rating.Contains(player.Rating) || !q.OpponentRating.Contains(opponent.Rating) {
+		return false
+	}
+	if len(q.Eco) > 0 && !matchEco(q.Eco, game.Opening.Eco) {
+		return false
+	}
+	if q.Opening != "" && !strings.Contains(strings.ToLower(game.Opening.Name), strings.ToLower(q.Opening)) {
+		return false
+	}
+	if len(q.Results) > 0 && !q.matchResult(game, color) {
+		return false
+	}
+	if len(q.Speeds) > 0 && !containsFold(q.Speeds, game.Speed) {
+		return false
+	}
+	if len(q.Variants) > 0 && !containsFold(q.Variants, game.Variant) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !containsFold(q.Statuses, game.Status) {
+		return false
+	}
+	if q.Rated != "" && q.Rated != strconv.FormatBool(game.Rated) {
+		return false
+	}
+	created := time.Unix(0, game.CreatedAt*int64(time.Millisecond)).UTC()
+	if !q.Since.IsZero() && created.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !created.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+func matchEco(patterns []string, eco string) bool {
+	if eco == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if i := strings.Index(p, ".."); i >= 0 {
+			lo, hi := p[:i], p[i+2:]
+			// The upper bound is a prefix like single values, so that
+			// A..B includes B20.
+			if (lo == "" || eco >= lo) && (hi == "" || eco <= hi || strings.HasPrefix(eco, hi)) {
+				return true
+			}
+		} else if strings.HasPrefix(eco, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) matchResult(game *Game, color string) bool {
+	result := GameResult(game)
+	for _, r := range q.Results {
+		switch r {
+		case "win":
+			if game.Winner != "" && game.Winner == color {
+				return true
+			}
+		case "loss":
+			if game.Winner != "" && game.Winner != color {
+				return true
+			}
+		case "draw":
+			if result == "1/2-1/2" {
+				return true
+			}
+		case "white", "black":
+			if game.Winner == r {
+				return true
+			}
+		default:
+			if result == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GameIndex keeps archived games with lookup tables for the exact match
+// terms of a query so that only candidate games are checked in full.
+type GameIndex struct {
+	Games     []Game
+	byPlayer  map[string][]int
+	bySpeed   map[string][]int
+	byVariant map[string][]int
+	byStatus  map[string][]int
+	byDate    []int
+}
+
+func NewGameIndex(games []Game) *GameIndex {
+	idx := &GameIndex{
+		Games:     games,
+		byPlayer:  make(map[string][]int),
+		bySpeed:   make(map[string][]int),
+		byVariant: make(map[string][]int),
+		byStatus:  make(map[string][]int),
+		byDate:    make([]int, len(games)),
+	}
+	for i := range games {
+		g := &games[i]
+		for _, p := range []GamePlayer{g.Players.White, g.Players.Black} {
+			for _, key := range []string{p.User.Name, p.User.ID} {
+				key = strings.ToLower(key)
+				if ids := idx.byPlayer[key]; key != "" && (len(ids) == 0 || ids[len(ids)-1] != i) {
+					idx.byPlayer[key] = append(ids, i)
+				}
+			}
+		}
+		idx.bySpeed[strings.ToLower(g.Speed)] = append(idx.bySpeed[strings.ToLower(g.Speed)], i)
+		idx.byVariant[strings.ToLower(g.Variant)] = append(idx.byVariant[strings.ToLower(g.Variant)], i)
+		idx.byStatus[strings.ToLower(g.Status)] = append(idx.byStatus[strings.ToLower(g.Status)], i)
+		idx.byDate[i] = i
+	}
+	sort.SliceStable(idx.byDate, func(a, b int) bool {
+		return games[idx.byDate[a]].CreatedAt < games[idx.byDate[b]].CreatedAt
+	})
+	return idx
+}
+
+func (a *Archive) Index(username string) (*GameIndex, error) {
+	games, err := a.Games(username)
+	if err != nil {
+		return nil, err
+	}
+	return NewGameIndex(games), nil
+}
+
+func postings(index map[string][]int, values ...string) []int {
+	var ids []int
+	for _, v := range values {
+		ids = append(ids, index[strings.ToLower(v)]...)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func intersect(a, b []int) []int {
+	out := make([]int, 0, len(a))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func (idx *GameIndex) dateRange(since, until time.Time) []int {
+	lo, hi := 0, len(idx.byDate)
+	if !since.IsZero() {
+		ms := since.UnixNano() / int64(time.Millisecond)
+		lo = sort.Search(len(idx.byDate), func(i int) bool {
+			return idx.Games[idx.byDate[i]].CreatedAt >= ms
+		})
+	}
+	if !until.IsZero() {
+		ms := until.UnixNano() / int64(time.Millisecond)
+		hi = sort.Search(len(idx.byDate), func(i int) bool {
+			return idx.Games[idx.byDate[i]].CreatedAt >= ms
+		})
+	}
+	if hi < lo {
+		hi = lo
+	}
+	ids := append([]int(nil), idx.byDate[lo:hi]...)
+	sort.Ints(ids)
+	return ids
+}
+
+// Search returns the games matching the query, most recent first.
+func (idx *GameIndex) Search(q *Query) []Game {
+	var candidates []int
+	narrow := func(ids []int) {
+		if candidates == nil {
+			candidates = ids
+		} else {
+			candidates = intersect(candidates, ids)
+		}
+	}
+	if q.Player != "" {
+		narrow(postings(idx.byPlayer, q.Player))
+	}
+	if q.Opponent != "" {
+		narrow(postings(idx.byPlayer, q.Opponent))
+	}
+	if len(q.Speeds) > 0 {
+		narrow(postings(idx.bySpeed, q.Speeds...))
+	}
+	if len(q.Variants) > 0 {
+		narrow(postings(idx.byVariant, q.Variants...))
+	}
+	if len(q.Statuses) > 0 {
+		narrow(postings(idx.byStatus, q.Statuses...))
+	}
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		narrow(idx.dateRange(q.Since, q.Until))
+	}
+	if candidates == nil {
+		candidates = make([]int, len(idx.Games))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	games := make([]Game, 0)
+	for _, i := range candidates {
+		if q.Match(&idx.Games[i]) {
+			games = append(games, idx.Games[i])
+		}
+	}
+	sort.SliceStable(games, func(i, j int) bool {
+		return games[i].CreatedAt > games[j].CreatedAt
+	})
+	return games
+}
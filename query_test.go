@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// mustParseGames decodes games from NDJSON fixture lines.
+func mustParseGames(ndjson string) []Game {
+	var games []Game
+	for _, line := range strings.Split(strings.TrimSpace(ndjson), "\n") {
+		var game Game
+		if err := json.Unmarshal([]byte(line), &game); err != nil {
+			panic(err)
+		}
+		games = append(games, game)
+	}
+	return games
+}
+
+var queryGames = mustParseGames(`
+{"id":"g1","rated":true,"variant":"standard","speed":"blitz","createdAt":1672876800000,"status":"resign","winner":"white","players":{"white":{"user":{"name":"alice","id":"alice"},"rating":1800},"black":{"user":{"name":"bob","id":"bob"},"rating":1900}},"opening":{"eco":"B90","name":"Sicilian Defense: Najdorf Variation"}}
+{"id":"g2","rated":true,"variant":"standard","speed":"rapid","createdAt":1675987200000,"status":"resign","winner":"white","players":{"white":{"user":{"name":"bob","id":"bob"},"rating":1910},"black":{"user":{"name":"alice","id":"alice"},"rating":1810}},"opening":{"eco":"C50","name":"Italian Game"}}
+{"id":"g3","rated":true,"variant":"standard","speed":"blitz","createdAt":1678838400000,"status":"draw","players":{"white":{"user":{"name":"carol","id":"carol"},"rating":2100},"black":{"user":{"name":"alice","id":"alice"},"rating":1820}},"opening":{"eco":"D37","name":"Queen's Gambit Declined"}}
+{"id":"g4","rated":true,"variant":"standard","speed":"bullet","createdAt":1681948800000,"status":"resign","winner":"black","players":{"white":{"user":{"name":"alice","id":"alice"},"rating":1830},"black":{"user":{"name":"dave","id":"dave"},"rating":1500}},"opening":{"eco":"B20","name":"Sicilian Defense"}}
+`)
+
+func searchIds(t *testing.T, query string) string {
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("Unexpected error for %q: %v", query, err)
+	}
+	ids := ""
+	for _, g := range NewGameIndex(queryGames).Search(q) {
+		ids += g.ID + " "
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		query string
+		ids   string
+	}{
+		{"player:alice", "g4 g3 g2 g1 "},
+		{"player:alice color:white", "g4 g1 "},
+		{"player:alice result:loss", "g4 g2 "},
+		{"player:alice opponent:bob result:win", "g1 "},
+		{"player:alice rating:1815..", "g4 g3 "},
+		{"player:alice opponentRating:..1600", "g4 "},
+		{"eco:B", "g4 g1 "},
+		{"eco:C00..D99", "g3 g2 "},
+		{"eco:A..B", "g4 g1 "},
+		{"eco:C00..C", "g2 "},
+		{"sicilian speed:blitz,bullet", "g4 g1 "},
+		{`opening:"queen's gambit"`, "g3 "},
+		{"result:draw", "g3 "},
+		{"result:1-0 speed:rapid", "g2 "},
+		{"date:2023-02-10..2023-03-15", "g3 g2 "},
+		{"date:2023-04-01..", "g4 "},
+		{"status:resign variant:standard rated:true", "g4 g2 g1 "},
+		{"player:erin", ""},
+	}
+	for _, test := range tests {
+		if ids := searchIds(t, test.query); ids != test.ids {
+			t.Errorf("%q: expected %q, got %q", test.query, test.ids, ids)
+		}
+	}
+}
+
+func TestSearchByUserID(t *testing.T) {
+	games := mustParseGames(`{"id":"g1","status":"resign","winner":"white","players":{"white":{"user":{"id":"alice"}},"black":{"user":{"name":"Bobby","id":"bob"}}}}`)
+	idx := NewGameIndex(games)
+	for _, query := range []string{"player:alice", "player:bob", "opponent:bob", "player:bobby opponent:alice"} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", query, err)
+		}
+		if found := idx.Search(q); len(found) != 1 || !q.Match(&games[0]) {
+			t.Errorf("%q: expected the game to be found by user ID, got %d games", query, len(found))
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"color:white",
+		"player:alice color:green",
+		"result:win",
+		"rating:abc",
+		"date:2023-13-01",
+		"foo:bar",
+		`opening:"open`,
+		"speed:",
+	} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
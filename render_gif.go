@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+	"time"
+)
+
+type GifOptions struct {
+	SquareSize  int
+	Flipped     bool
+	Coordinates bool
+	Delay       time.Duration
+	LastDelay   time.Duration
+}
+
+func NewGifOptions() GifOptions {
+	return GifOptions{
+		SquareSize:  40,
+		Coordinates: true,
+		Delay:       time.Second,
+		LastDelay:   3 * time.Second,
+	}
+}
+
+const (
+	gifLight uint8 = iota
+	gifDark
+	gifLightHighlight
+	gifDarkHighlight
+	gifWhitePiece
+	gifBlackPiece
+	gifWhiteOutline
+	gifBlackOutline
+	gifHeader
+	gifText
+)
+
+var gifPalette = color.Palette{
+	color.RGBA{0xf0, 0xd9, 0xb5, 0xff},
+	color.RGBA{0xb5, 0x88, 0x63, 0xff},
+	color.RGBA{0xcd, 0xd2, 0x6a, 0xff},
+	color.RGBA{0xaa, 0xa2, 0x3a, 0xff},
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+	color.RGBA{0x20, 0x20, 0x20, 0xff},
+	color.RGBA{0x00, 0x00, 0x00, 0xff},
+	color.RGBA{0x70, 0x70, 0x70, 0xff},
+	color.RGBA{0x26, 0x24, 0x21, 0xff},
+	color.RGBA{0xe0, 0xe0, 0xe0, 0xff},
+}
+
+var pieceSprites = map[PieceType][16]string{
+	Pawn: {
+		"................",
+		"................",
+		"................",
+		".......##.......",
+		"......####......",
+		"......####......",
+		".......##.......",
+		"......####......",
+		".......##.......",
+		".......##.......",
+		"......####......",
+		".....######.....",
+		"....########....",
+		"....########....",
+		"................",
+		"................",
+	},
+	Knight: {
+		"................",
+		"................",
+		"......##........",
+		".....####.......",
+		"....######......",
+		"...########.....",
+		"..###.#####.....",
+		"..#########.....",
+		"...##..####.....",
+		"......#####.....",
+		".....######.....",
+		"....#######.....",
+		"...#########....",
+		"...##########...",
+		"................",
+		"................",
+	},
+	Bishop: {
+		"................",
+		"................",
+		".......##.......",
+		"......####......",
+		".....##.###.....",
+		".....#.####.....",
+		".....######.....",
+		"......####......",
+		".......##.......",
+		"......####......",
+		".......##.......",
+		".....######.....",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	Rook: {
+		"................",
+		"................",
+		"...##..##..##...",
+		"...##########...",
+		"...##########...",
+		"....########....",
+		".....######.....",
+		".....######.....",
+		".....######.....",
+		".....######.....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	Queen: {
+		"................",
+		"..#....##....#..",
+		"..##..####..##..",
+		"...##.####.##...",
+		"...##########...",
+		"....########....",
+		"....########....",
+		".....######.....",
+		".....######.....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+	King: {
+		"................",
+		".......##.......",
+		"......####......",
+		".......##.......",
+		"....##.##.##....",
+		"...##########...",
+		"...##########...",
+		"....########....",
+		".....######.....",
+		".....######.....",
+		"....########....",
+		"...##########...",
+		"...##########...",
+		"...##########...",
+		"................",
+		"................",
+	},
+}
+
+var glyphs = map[rune][7]string{
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	' ': {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	':': {".....", ".##..", ".##..", ".....", ".##..", ".##..", "....."},
+	'.': {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	'-': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'_': {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	'(': {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')': {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+}
+
+// drawText writes s with the built-in 5x7 font, scaled by scale, and
+// returns the x coordinate after the last character. Letters are drawn in
+// upper case.
+func drawText(img *image.Paletted, x, y, scale int, s string, c uint8) int {
+	for _, r := range strings.ToUpper(s) {
+		g, ok := glyphs[r]
+		if !ok {
+			g = glyphs['?']
+		}
+		for gy, row := range g {
+			for gx := 0; gx < len(row); gx++ {
+				if row[gx] != '#' {
+					continue
+				}
+				fillRect(img, x+gx*scale, y+gy*scale, scale, scale, c)
+			}
+		}
+		x += 6 * scale
+	}
+	return x
+}
+
+func textWidth(s string, scale int) int {
+	return len([]rune(s)) * 6 * scale
+}
+
+func fillRect(img *image.Paletted, x, y, w, h int, c uint8) {
+	for py := y; py < y+h; py++ {
+		for px := x; px < x+w; px++ {
+			img.SetColorIndex(px, py, c)
+		}
+	}
+}
+
+func spriteAt(sprite [16]string, x, y int) bool {
+	return x >= 0 && y >= 0 && x < 16 && y < 16 && sprite[y][x] == '#'
+}
+
+func drawPiece(img *image.Paletted, x0, y0, size int, pc Piece) {
+	sprite := pieceSprites[pc.Type]
+	fill, outline := gifWhitePiece, gifWhiteOutline
+	if pc.Color == Black {
+		fill, outline = gifBlackPiece, gifBlackOutline
+	}
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			sx, sy := px*16/size, py*16/size
+			if !spriteAt(sprite, sx, sy) {
+				continue
+			}
+			c := fill
+			if !spriteAt(sprite, sx-1, sy) || !spriteAt(sprite, sx+1, sy) || !spriteAt(sprite, sx, sy-1) || !spriteAt(sprite, sx, sy+1) {
+				c = outline
+			}
+			img.SetColorIndex(x0+px, y0+py, c)
+		}
+	}
+}
+
+// drawBoard renders the position at the given vertical offset.
+func drawBoard(img *image.Paletted, top int, p *Position, last Move, opts GifOptions) {
+	size := opts.SquareSize
+	for i, pc := range p.Board {
+		sq := Square(i)
+		col, row := boardCell(sq, opts.Flipped)
+		x, y := col*size, top+row*size
+		c := gifDark
+		if isLightSquare(sq) {
+			c = gifLight
+		}
+		if last != NullMove && (sq == last.From || sq == last.To) {
+			c += gifLightHighlight
+		}
+		fillRect(img, x, y, size, size, c)
+		if pc.Type != NoPieceType {
+			drawPiece(img, x, y, size, pc)
+		}
+	}
+
+	if !opts.Coordinates || size < 24 {
+		return
+	}
+	for i := 0; i < 8; i++ {
+		file, rank := NewSquare(i, 0), NewSquare(0, i)
+		if opts.Flipped {
+			file, rank = NewSquare(i, 7), NewSquare(7, i)
+		}
+		col, row := boardCell(file, opts.Flipped)
+		drawText(img, (col+1)*size-7, top+(row+1)*size-9, 1, string(rune('a'+file.File())), coordinateIndex(file))
+		col, row = boardCell(rank, opts.Flipped)
+		drawText(img, col*size+2, top+row*size+2, 1, fmt.Sprint(rank.Rank()+1), coordinateIndex(rank))
+	}
+}
+
+func coordinateIndex(sq Square) uint8 {
+	if isLightSquare(sq) {
+		return gifDark
+	}
+	return gifLight
+}
+
+func formatClock(centis int) string {
+	seconds := centis / 100
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+func gifPlayerLabel(p GamePlayer) string {
+	label := gamePlayerName(p)
+	if p.Rating > 0 {
+		label += fmt.Sprintf(" (%d)", p.Rating)
+	}
+	return label
+}
+
+// drawHeader writes both players with their clocks, white on the left.
+func drawHeader(img *image.Paletted, game *Game, clocks [2]int, hasClocks bool) {
+	width := img.Bounds().Dx()
+	fillRect(img, 0, 0, width, gifHeaderHeight, gifHeader)
+	white := gifPlayerLabel(game.Players.White)
+	black := gifPlayerLabel(game.Players.Black)
+	if hasClocks {
+		white += " " + formatClock(clocks[White])
+		black = formatClock(clocks[Black]) + " " + black
+	}
+	y := (gifHeaderHeight - 7) / 2
+	drawText(img, 4, y, 1, white, gifText)
+	drawText(img, width-4-textWidth(black, 1)+1, y, 1, black, gifText)
+}
+
+const gifHeaderHeight = 20
+
+// RenderGameGIF writes an animated GIF with one frame per position of the
+// game, headed by the player names and their clocks.
+func RenderGameGIF(w io.Writer, game *Game, opts GifOptions) error {
+	if opts.SquareSize <= 0 {
+		opts.SquareSize = NewGifOptions().SquareSize
+	}
+	replayed, err := ReplayGame(game)
+	if err != nil {
+		return err
+	}
+
+	hasClocks := len(game.Clocks) > 0
+	clocks := [2]int{game.Clock.Initial * 100, game.Clock.Initial * 100}
+	start := gameStartColor(game)
+	bounds := image.Rect(0, 0, 8*opts.SquareSize, 8*opts.SquareSize+gifHeaderHeight)
+
+	anim := &gif.GIF{}
+	addFrame := func(p *Position, last Move, delay time.Duration) {
+		img := image.NewPaletted(bounds, gifPalette)
+		drawHeader(img, game, clocks, hasClocks)
+		drawBoard(img, gifHeaderHeight, p, last, opts)
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+	}
+
+	addFrame(replayed.Initial, NullMove, opts.Delay)
+	for i, ply := range replayed.Plies {
+		if i < len(game.Clocks) {
+			mover := start
+			if i%2 == 1 {
+				mover = start.Other()
+			}
+			clocks[mover] = game.Clocks[i]
+		}
+		delay := opts.Delay
+		if i == len(replayed.Plies)-1 {
+			delay = opts.LastDelay
+		}
+		addFrame(ply.Position, ply.Move, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+type Arrow struct {
+	From  Square
+	To    Square
+	Color string
+}
+
+type BoardImageOptions struct {
+	SquareSize     int
+	Flipped        bool
+	Coordinates    bool
+	LastMove       Move
+	Arrows         []Arrow
+	LightColor     string
+	DarkColor      string
+	HighlightColor string
+	ArrowColor     string
+}
+
+func NewBoardImageOptions() BoardImageOptions {
+	return BoardImageOptions{
+		SquareSize:     45,
+		Coordinates:    true,
+		LastMove:       NullMove,
+		LightColor:     "#f0d9b5",
+		DarkColor:      "#b58863",
+		HighlightColor: "#9bc700",
+		ArrowColor:     "#15781b",
+	}
+}
+
+var svgPieceGlyphs = map[PieceType]string{
+	Pawn:   "♟",
+	Knight: "♞",
+	Bishop: "♝",
+	Rook:   "♜",
+	Queen:  "♛",
+	King:   "♚",
+}
+
+// boardCell returns the column and row at which a square is drawn.
+func boardCell(sq Square, flipped bool) (int, int) {
+	if flipped {
+		return 7 - sq.File(), sq.Rank()
+	}
+	return sq.File(), 7 - sq.Rank()
+}
+
+func isLightSquare(sq Square) bool {
+	return (sq.File()+sq.Rank())%2 == 1
+}
+
+// RenderSVG draws the position as an SVG diagram.
+func RenderSVG(w io.Writer, p *Position, opts BoardImageOptions) error {
+	size := opts.SquareSize
+	if size <= 0 {
+		size = NewBoardImageOptions().SquareSize
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", 8*size, 8*size, 8*size, 8*size)
+
+	if len(opts.Arrows) > 0 {
+		bw.WriteString("<defs>\n")
+		for i, a := range opts.Arrows {
+			fmt.Fprintf(bw, `<marker id="arrowhead-%d" viewBox="0 0 4 4" refX="2" refY="2" markerWidth="4" markerHeight="4" orient="auto"><path d="M0,0 L4,2 L0,4 z" fill="%s"/></marker>`+"\n", i, arrowColor(a, opts))
+		}
+		bw.WriteString("</defs>\n")
+	}
+
+	for i := range p.Board {
+		sq := Square(i)
+		col, row := boardCell(sq, opts.Flipped)
+		fill := opts.DarkColor
+		if isLightSquare(sq) {
+			fill = opts.LightColor
+		}
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", col*size, row*size, size, size, fill)
+		if opts.LastMove != NullMove && (sq == opts.LastMove.From || sq == opts.LastMove.To) {
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="0.4"/>`+"\n", col*size, row*size, size, size, opts.HighlightColor)
+		}
+	}
+
+	if opts.Coordinates {
+		font := size / 5
+		for i := 0; i < 8; i++ {
+			file := NewSquare(i, 0)
+			rank := NewSquare(0, i)
+			if opts.Flipped {
+				file, rank = NewSquare(i, 7), NewSquare(7, i)
+			}
+			col, row := boardCell(file, opts.Flipped)
+			fmt.Fprintf(bw, `<text x="%d" y="%d" font-size="%d" font-family="sans-serif" fill="%s" text-anchor="end">%c</text>`+"\n",
+				(col+1)*size-2, (row+1)*size-3, font, coordinateColor(file, opts), 'a'+file.File())
+			col, row = boardCell(rank, opts.Flipped)
+			fmt.Fprintf(bw, `<text x="%d" y="%d" font-size="%d" font-family="sans-serif" fill="%s">%d</text>`+"\n",
+				col*size+2, row*size+font+1, font, coordinateColor(rank, opts), rank.Rank()+1)
+		}
+	}
+
+	for i, pc := range p.Board {
+		if pc.Type == NoPieceType {
+			continue
+		}
+		col, row := boardCell(Square(i), opts.Flipped)
+		fill, stroke := "#ffffff", "#000000"
+		if pc.Color == Black {
+			fill = "#000000"
+		}
+		fmt.Fprintf(bw, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central" fill="%s" stroke="%s" stroke-width="1">%s</text>`+"\n",
+			col*size+size/2, row*size+size/2, size*4/5, fill, stroke, svgPieceGlyphs[pc.Type])
+	}
+
+	for i, a := range opts.Arrows {
+		fromCol, fromRow := boardCell(a.From, opts.Flipped)
+		toCol, toRow := boardCell(a.To, opts.Flipped)
+		fmt.Fprintf(bw, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="%d" stroke-opacity="0.8" stroke-linecap="round" marker-end="url(#arrowhead-%d)"/>`+"\n",
+			fromCol*size+size/2, fromRow*size+size/2, toCol*size+size/2, toRow*size+size/2, arrowColor(a, opts), size/6, i)
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+func arrowColor(a Arrow, opts BoardImageOptions) string {
+	if a.Color != "" {
+		return a.Color
+	}
+	return opts.ArrowColor
+}
+
+func coordinateColor(sq Square, opts BoardImageOptions) string {
+	if isLightSquare(sq) {
+		return opts.DarkColor
+	}
+	return opts.LightColor
+}
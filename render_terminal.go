@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type TerminalOptions struct {
+	Unicode     bool
+	Colors      bool
+	Coordinates bool
+	Flipped     bool
+	LastMove    Move
+}
+
+func NewTerminalOptions() TerminalOptions {
+	return TerminalOptions{
+		Unicode:     true,
+		Colors:      true,
+		Coordinates: true,
+		LastMove:    NullMove,
+	}
+}
+
+const (
+	ansiReset          = "\x1b[0m"
+	ansiLight          = "\x1b[48;5;180m"
+	ansiDark           = "\x1b[48;5;137m"
+	ansiLightHighlight = "\x1b[48;5;186m"
+	ansiDarkHighlight  = "\x1b[48;5;143m"
+	ansiWhitePiece     = "\x1b[1;97m"
+	ansiBlackPiece     = "\x1b[1;30m"
+)
+
+var unicodePieces = [2]map[PieceType]string{
+	{Pawn: "♙", Knight: "♘", Bishop: "♗", Rook: "♖", Queen: "♕", King: "♔"},
+	{Pawn: "♟", Knight: "♞", Bishop: "♝", Rook: "♜", Queen: "♛", King: "♚"},
+}
+
+func terminalPiece(pc Piece, opts TerminalOptions) string {
+	switch {
+	case pc.Type == NoPieceType && opts.Colors:
+		return " "
+	case pc.Type == NoPieceType && opts.Unicode:
+		return "·"
+	case pc.Type == NoPieceType:
+		return "."
+	case opts.Unicode && opts.Colors:
+		return unicodePieces[Black][pc.Type]
+	case opts.Unicode:
+		return unicodePieces[pc.Color][pc.Type]
+	}
+	return pc.String()
+}
+
+// RenderTerminal draws the position as text for a terminal, one rank per
+// line, with optional ANSI colored squares.
+func RenderTerminal(p *Position, opts TerminalOptions) string {
+	var sb strings.Builder
+	for row := 0; row < 8; row++ {
+		rank := 7 - row
+		if opts.Flipped {
+			rank = row
+		}
+		if opts.Coordinates {
+			fmt.Fprintf(&sb, "%d ", rank+1)
+		}
+		for col := 0; col < 8; col++ {
+			file := col
+			if opts.Flipped {
+				file = 7 - col
+			}
+			sq := NewSquare(file, rank)
+			pc := p.Board[sq]
+			if !opts.Colors {
+				if col > 0 {
+					sb.WriteByte(' ')
+				}
+				sb.WriteString(terminalPiece(pc, opts))
+				continue
+			}
+
+			highlight := opts.LastMove != NullMove && (sq == opts.LastMove.From || sq == opts.LastMove.To)
+			switch {
+			case isLightSquare(sq) && highlight:
+				sb.WriteString(ansiLightHighlight)
+			case isLightSquare(sq):
+				sb.WriteString(ansiLight)
+			case highlight:
+				sb.WriteString(ansiDarkHighlight)
+			default:
+				sb.WriteString(ansiDark)
+			}
+			if pc.Color == White {
+				sb.WriteString(ansiWhitePiece)
+			} else {
+				sb.WriteString(ansiBlackPiece)
+			}
+			sb.WriteString(" " + terminalPiece(pc, opts) + " ")
+		}
+		if opts.Colors {
+			sb.WriteString(ansiReset)
+		}
+		sb.WriteByte('\n')
+	}
+
+	if opts.Coordinates {
+		sb.WriteString("  ")
+		for col := 0; col < 8; col++ {
+			file := col
+			if opts.Flipped {
+				file = 7 - col
+			}
+			if opts.Colors {
+				fmt.Fprintf(&sb, " %c ", 'a'+file)
+			} else {
+				if col > 0 {
+					sb.WriteByte(' ')
+				}
+				sb.WriteByte(byte('a' + file))
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func stepHeader(replayed *ReplayedGame, ply int) string {
+	game := replayed.Game
+	header := ""
+	if game != nil {
+		header = fmt.Sprintf("%s - %s  ", gamePlayerName(game.Players.White), gamePlayerName(game.Players.Black))
+	}
+	if ply == 0 {
+		return header + "start"
+	}
+	p := replayed.Plies[ply-1]
+	dots := "."
+	if p.Color == Black {
+		dots = "..."
+	}
+	number := (replayed.Initial.Ply()+ply-1)/2 + 1
+	return fmt.Sprintf("%sply %d/%d  %d%s %s", header, ply, len(replayed.Plies), number, dots, p.SAN)
+}
+
+// StepThrough shows the replayed game one position at a time, reading a
+// command per line from in: enter or n for the next move, p for the
+// previous one, f and l for the first and last position, a ply number to
+// jump to it and q to quit.
+func StepThrough(in io.Reader, out io.Writer, replayed *ReplayedGame, opts TerminalOptions) error {
+	scanner := bufio.NewScanner(in)
+	ply := 0
+	for {
+		view := opts
+		pos := replayed.Initial
+		view.LastMove = NullMove
+		if ply > 0 {
+			pos = replayed.Plies[ply-1].Position
+			view.LastMove = replayed.Plies[ply-1].Move
+		}
+		fmt.Fprintf(out, "%s\n%s", stepHeader(replayed, ply), RenderTerminal(pos, view))
+		if ply == len(replayed.Plies) && replayed.Game != nil {
+			fmt.Fprintf(out, "%s %s\n", GameResult(replayed.Game), replayed.Game.Status)
+		}
+		fmt.Fprint(out, "[n]ext [p]rev [f]irst [l]ast [q]uit> ")
+
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		cmd := strings.TrimSpace(scanner.Text())
+		switch cmd {
+		case "", "n":
+			if ply < len(replayed.Plies) {
+				ply++
+			}
+		case "p":
+			if ply > 0 {
+				ply--
+			}
+		case "f":
+			ply = 0
+		case "l":
+			ply = len(replayed.Plies)
+		case "q":
+			return nil
+		default:
+			n, err := strconv.Atoi(cmd)
+			if err != nil || n < 0 || n > len(replayed.Plies) {
+				fmt.Fprintf(out, "unknown command %q\n", cmd)
+				continue
+			}
+			ply = n
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderTerminal(t *testing.T) {
+	opts := NewTerminalOptions()
+	opts.Unicode = false
+	opts.Colors = false
+	board := RenderTerminal(NewPosition(), opts)
+	expected := `8 r n b q k b n r
+7 p p p p p p p p
+6 . . . . . . . .
+5 . . . . . . . .
+4 . . . . . . . .
+3 . . . . . . . .
+2 P P P P P P P P
+1 R N B Q K B N R
+  a b c d e f g h
+`
+	if board != expected {
+		t.Errorf("Unexpected ASCII board:\n%s", board)
+	}
+
+	opts.Unicode = true
+	opts.Flipped = true
+	opts.Coordinates = false
+	board = RenderTerminal(NewPosition(), opts)
+	lines := strings.Split(board, "\n")
+	if len(lines) != 9 || lines[0] != "♖ ♘ ♗ ♔ ♕ ♗ ♘ ♖" || lines[7] != "♜ ♞ ♝ ♚ ♛ ♝ ♞ ♜" {
+		t.Errorf("Unexpected flipped board:\n%s", board)
+	}
+
+	pos := NewPosition()
+	e4, _ := pos.ParseSAN("e4")
+	opts = NewTerminalOptions()
+	opts.LastMove = e4
+	board = RenderTerminal(pos.Play(e4), opts)
+	if strings.Count(board, ansiLightHighlight)+strings.Count(board, ansiDarkHighlight) != 2 || strings.Count(board, ansiReset) != 8 {
+		t.Errorf("Expected two highlighted squares and a reset per rank")
+	}
+	if !strings.Contains(board, ansiWhitePiece+" ♟ ") {
+		t.Errorf("Expected white pawns drawn in white")
+	}
+}
+
+func TestStepThrough(t *testing.T) {
+	game := &Game{Moves: "e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#", Status: "mate", Winner: "white"}
+	game.Players.White.User.Name = "Alice"
+	game.Players.Black.User.Name = "Bob"
+	replayed, _ := ReplayGame(game)
+
+	opts := NewTerminalOptions()
+	opts.Colors = false
+	var out bytes.Buffer
+	if err := StepThrough(strings.NewReader("\nn\np\nl\nx\n4\nq\n"), &out, replayed, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		"Alice - Bob  start",
+		"Alice - Bob  ply 1/7  1. e4",
+		"Alice - Bob  ply 2/7  1... e5",
+		"ply 7/7  4. Qxf7#",
+		"1-0 mate",
+		`unknown command "x"`,
+		"ply 4/7  2... Nc6",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+	if n := strings.Count(s, "[n]ext"); n != 7 {
+		t.Errorf("Expected 7 prompts, got %d", n)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderSVG(t *testing.T) {
+	pos := NewPosition()
+	e4, _ := pos.ParseSAN("e4")
+	pos = pos.Play(e4)
+
+	opts := NewBoardImageOptions()
+	opts.LastMove = e4
+	opts.Arrows = []Arrow{{From: NewSquare(6, 7), To: NewSquare(5, 5)}}
+	var buf bytes.Buffer
+	if err := RenderSVG(&buf, pos, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Errorf("Expected a complete SVG document")
+	}
+	if n := strings.Count(svg, "♟"); n != 16 {
+		t.Errorf("Expected 16 pawns, got %d", n)
+	}
+	if n := strings.Count(svg, `fill-opacity="0.4"`); n != 2 {
+		t.Errorf("Expected 2 highlighted squares, got %d", n)
+	}
+	if !strings.Contains(svg, `<line x1="292" y1="22" x2="247" y2="112"`) || !strings.Contains(svg, "arrowhead-0") {
+		t.Errorf("Expected an arrow from g8 to f6")
+	}
+	if !strings.Contains(svg, `<rect x="180" y="180" width="45" height="45" fill="#f0d9b5"/>`) {
+		t.Errorf("Expected e4 to be a light square at column 4, row 4")
+	}
+
+	opts.Flipped = true
+	opts.Coordinates = false
+	opts.Arrows = nil
+	buf.Reset()
+	RenderSVG(&buf, pos, opts)
+	svg = buf.String()
+	if !strings.Contains(svg, `<rect x="135" y="135" width="45" height="45" fill="#f0d9b5"/>`) {
+		t.Errorf("Expected e4 at column 3, row 3 when flipped")
+	}
+	if strings.Contains(svg, "sans-serif") || strings.Contains(svg, "<defs>") {
+		t.Errorf("Expected no coordinates or arrows")
+	}
+}
+
+func TestRenderGameGIF(t *testing.T) {
+	game := &Game{Moves: "e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#", Clocks: []int{18000, 18000, 17500, 17900, 17000, 17100, 16000}}
+	game.Clock.Initial = 180
+	game.Players.White.User.Name = "Alice"
+	game.Players.White.Rating = 1500
+	game.Players.Black.User.Name = "Bob"
+
+	opts := NewGifOptions()
+	opts.SquareSize = 24
+	opts.Delay = 500 * time.Millisecond
+	var buf bytes.Buffer
+	if err := RenderGameGIF(&buf, game, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding: %v", err)
+	}
+	if len(anim.Image) != 8 || anim.Delay[0] != 50 || anim.Delay[7] != 300 {
+		t.Errorf("Expected 8 frames with delays, got %d %v", len(anim.Image), anim.Delay)
+	}
+	if b := anim.Image[0].Bounds(); b.Dx() != 192 || b.Dy() != 192+gifHeaderHeight {
+		t.Errorf("Unexpected frame size %v", b)
+	}
+
+	last := anim.Image[7]
+	if got := last.ColorIndexAt(5*24+1, gifHeaderHeight+1*24+1); got != gifLightHighlight {
+		t.Errorf("Expected f7 to be highlighted, got color %d", got)
+	}
+	textPixels := 0
+	for x := 0; x < 192; x++ {
+		for y := 0; y < gifHeaderHeight; y++ {
+			if last.ColorIndexAt(x, y) == gifText {
+				textPixels++
+			}
+		}
+	}
+	if textPixels == 0 {
+		t.Errorf("Expected the header to contain text")
+	}
+
+	if formatClock(18000) != "3:00" || formatClock(370000) != "1:01:40" {
+		t.Errorf("Unexpected clock format")
+	}
+	if err := RenderGameGIF(&buf, &Game{Moves: "e4 e4"}, opts); err == nil {
+		t.Errorf("Expected an error for an illegal game")
+	}
+}
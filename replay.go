@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Ply struct {
+	Number    int
+	SAN       string
+	UCI       string
+	Fen       string
+	Move      Move
+	Color     Color
+	Captured  Piece
+	Check     bool
+	Checkmate bool
+	Position  *Position
+	Hash      uint64
+	Draw      DrawState
+}
+
+type ReplayError struct {
+	Ply  int
+	Move string
+	Fen  string
+	Err  error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("ply %d (%s): %v", e.Ply, e.Move, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+type ReplayedGame struct {
+	Game    *Game
+	Initial *Position
+	Plies   []Ply
+}
+
+func (r *ReplayedGame) Final() *Position {
+	if len(r.Plies) == 0 {
+		return r.Initial
+	}
+	return r.Plies[len(r.Plies)-1].Position
+}
+
+// DrawState reports the draw rules that apply after the last replayed move.
+func (r *ReplayedGame) DrawState() DrawState {
+	if len(r.Plies) == 0 {
+		return r.Initial.DrawState(1)
+	}
+	return r.Plies[len(r.Plies)-1].Draw
+}
+
+// FirstClaimableDraw returns the first ply at which a draw could have been
+// claimed or was reached automatically, or 0 if there was none.
+func (r *ReplayedGame) FirstClaimableDraw() int {
+	for _, ply := range r.Plies {
+		if ply.Draw.Claimable() || ply.Draw.Automatic() {
+			return ply.Number
+		}
+	}
+	return 0
+}
+
+func ReplayGame(game *Game) (*ReplayedGame, error) {
+	initial, err := variantInitialPosition(game.Variant, game.InitialFen)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := &ReplayedGame{Game: game, Initial: initial}
+	replayed.Plies, err = replayMoves(initial, strings.Fields(game.Moves))
+	return replayed, err
+}
+
+func Replay(fen string, moves string) ([]Ply, error) {
+	return ReplayVariant(VariantStandard, fen, moves)
+}
+
+func ReplayVariant(variant, fen string, moves string) ([]Ply, error) {
+	pos, err := variantInitialPosition(variant, fen)
+	if err != nil {
+		return nil, err
+	}
+	return replayMoves(pos, strings.Fields(moves))
+}
+
+func variantInitialPosition(variant, fen string) (*Position, error) {
+	if variant == "" {
+		variant = VariantStandard
+	}
+	if fen == "" {
+		start, err := VariantStartingFen(variant)
+		if err != nil {
+			return nil, err
+		}
+		fen = start
+	}
+	return ParseVariantFen(variant, fen)
+}
+
+func replayMoves(pos *Position, moves []string) ([]Ply, error) {
+	plies := make([]Ply, 0, len(moves))
+	history := NewPositionHistory(pos)
+	for i, san := range moves {
+		m, err := pos.ParseSAN(san)
+		if err != nil {
+			return plies, &ReplayError{Ply: i + 1, Move: san, Fen: pos.Fen(), Err: err}
+		}
+
+		captured := pos.Board[m.To]
+		if m.Castling {
+			captured = NoPiece
+		} else if pos.Board[m.From].Type == Pawn && captured.Type == NoPieceType && m.From.File() != m.To.File() {
+			captured = Piece{Type: Pawn, Color: pos.Turn.Other()}
+		}
+
+		next := pos.Play(m)
+		check := next.InCheck()
+		repetitions := history.Push(next)
+		plies = append(plies, Ply{
+			Number:    i + 1,
+			SAN:       pos.SAN(m),
+			UCI:       pos.UCI(m),
+			Fen:       next.Fen(),
+			Move:      m,
+			Color:     pos.Turn,
+			Captured:  captured,
+			Check:     check,
+			Checkmate: next.IsCheckmate(),
+			Position:  next,
+			Hash:      history.Hashes[len(history.Hashes)-1],
+			Draw:      next.DrawState(repetitions),
+		})
+		pos = next
+	}
+	return plies, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplayGame(t *testing.T) {
+	game := Game{Moves: "e4 d5 exd5 Qxd5 Nc3 Qa5 d4 c6 Nf3 Bg4 Bf4 e6 h3 Bxf3 Qxf3 Bb4 Be2 Nd7 a3 O-O-O axb4 Qxa1+ Kd2 Qxh1 Qxc6+ bxc6 Ba6#"}
+
+	replayed, err := ReplayGame(&game)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(replayed.Plies) != 27 {
+		t.Fatalf("Expected 27 plies, got %d", len(replayed.Plies))
+	}
+
+	exd5 := replayed.Plies[2]
+	if exd5.UCI != "e4d5" || exd5.Captured != (Piece{Type: Pawn, Color: Black}) || exd5.Color != White {
+		t.Errorf("Unexpected ply %+v", exd5)
+	}
+	if castle := replayed.Plies[19]; castle.UCI != "e8c8" || castle.Fen != "2kr2nr/pp1n1ppp/2p1p3/q7/1b1P1B2/P1N2Q1P/1PP1BPP1/R3K2R w KQ - 1 11" {
+		t.Errorf("Unexpected castling ply %s %s", castle.UCI, castle.Fen)
+	}
+	if check := replayed.Plies[21]; !check.Check || check.Checkmate || check.Captured.Type != Rook {
+		t.Errorf("Expected Qxa1+ to be a rook capture with check")
+	}
+	if last := replayed.Plies[26]; !last.Checkmate || !replayed.Final().IsCheckmate() {
+		t.Errorf("Expected the game to end in checkmate")
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	plies, err := Replay("", "e4 e5 Nf3 Ke7 Ng5 Qe8 Nf7 Kf6")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plies) != 8 || plies[7].Fen != "rnb1qbnr/pppp1Npp/5k2/4p3/4P3/8/PPPP1PPP/RNBQKB1R w KQ - 1 5" {
+		t.Errorf("Unexpected final position %s", plies[len(plies)-1].Fen)
+	}
+
+	plies, err = Replay("", "e4 e5 Nf3 Nc6 Bb5 Nf6 Bxf7 Nxe4")
+	var replayErr *ReplayError
+	if !errors.As(err, &replayErr) || replayErr.Ply != 7 || replayErr.Move != "Bxf7" || len(plies) != 6 {
+		t.Errorf("Expected an error at ply 7, got %v with %d plies", err, len(plies))
+	}
+
+	_, err = Replay("4k3/8/8/8/8/8/8/4K3 b - - 0 1", "Kd7 Kd2 Kd6 Kd3 Zz9")
+	if !errors.As(err, &replayErr) || replayErr.Ply != 5 {
+		t.Errorf("Expected an unparsable move at ply 5, got %v", err)
+	}
+}
+
+func TestReplayVariantEndWithCheck(t *testing.T) {
+	plies, err := ReplayVariant(VariantThreeCheck, "", "e4 e5 Bc4 Nc6 Bxf7+ Kxf7 Qh5+ g6 Qxg6+")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	last := plies[len(plies)-1]
+	if !last.Check || last.Checkmate || last.SAN != "Qxg6+" || last.Position.IsCheckmate() {
+		t.Errorf("Expected the third check not to be a checkmate, got %+v", last)
+	}
+}
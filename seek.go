@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SeekParams struct {
+	Rated bool
+	// Time and Increment set the clock of a real-time seek.
+	Time      time.Duration
+	Increment time.Duration
+	// Days sets the days per turn of a correspondence seek.
+	Days    int
+	Variant string
+	Color   string
+	// RatingMin and RatingMax restrict the opponents. The range is only
+	// sent when both are set.
+	RatingMin int
+	RatingMax int
+}
+
+func (p SeekParams) Values() url.Values {
+	values := url.Values{}
+	values.Set("rated", strconv.FormatBool(p.Rated))
+	if p.Days > 0 {
+		values.Set("days", strconv.Itoa(p.Days))
+	} else {
+		values.Set("time", strconv.FormatFloat(p.Time.Minutes(), 'f', -1, 64))
+		values.Set("increment", strconv.Itoa(int(p.Increment/time.Second)))
+	}
+	if p.Variant != "" {
+		values.Set("variant", p.Variant)
+	}
+	if p.Color != "" {
+		values.Set("color", p.Color)
+	}
+	if p.RatingMin > 0 && p.RatingMax > 0 {
+		values.Set("ratingRange", fmt.Sprintf("%d-%d", p.RatingMin, p.RatingMax))
+	}
+	return values
+}
+
+// Seek is a real-time seek, open as long as its connection is held.
+type Seek struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	accepted bool
+	err      error
+}
+
+// Seek creates a real-time seek. It stays open until an opponent accepts
+// it, which starts a game announced on the event stream, or until it is
+// canceled.
+func (c *Client) Seek(params SeekParams) (*Seek, error) {
+	if params.Days > 0 || params.Time+params.Increment <= 0 {
+		return nil, errors.New("a real-time seek needs a time and no days per turn")
+	}
+	req, err := c.newPostRequest("/api/board/seek", params.Values())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := c.HttpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	seek := &Seek{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(seek.done)
+		defer resp.Body.Close()
+		_, err := io.Copy(io.Discard, resp.Body)
+		seek.mu.Lock()
+		defer seek.mu.Unlock()
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			seek.err = err
+		default:
+			seek.accepted = true
+		}
+	}()
+	return seek, nil
+}
+
+// Done is closed when the seek is accepted, canceled or lost.
+func (s *Seek) Done() <-chan struct{} {
+	return s.done
+}
+
+// Cancel closes the connection, which removes the seek, and waits for it.
+func (s *Seek) Cancel() {
+	s.cancel()
+	<-s.done
+}
+
+// Accepted reports whether the server closed the seek because a game
+// started.
+func (s *Seek) Accepted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+// Err returns the error that dropped the connection, if any.
+func (s *Seek) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SeekCorrespondence creates a correspondence seek and returns its ID. It
+// stays open without a connection until an opponent accepts it.
+func (c *Client) SeekCorrespondence(params SeekParams) (string, error) {
+	if params.Days <= 0 {
+		return "", errors.New("a correspondence seek needs days per turn")
+	}
+	req, err := c.newPostRequest("/api/board/seek", params.Values())
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeek(t *testing.T) {
+	accept := make(chan struct{})
+	canceled := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/board/seek" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		if days := r.PostForm.Get("days"); days != "" {
+			if r.PostForm.Get("ratingRange") != "1500-1800" || r.PostForm.Get("color") != "white" || r.PostForm.Get("variant") != VariantChess960 {
+				http.Error(w, `{"error":"unexpected form `+r.PostForm.Encode()+`"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"id":"seek`+days+`"}`)
+			return
+		}
+		if r.PostForm.Get("time") != "2.5" || r.PostForm.Get("increment") != "1" || r.PostForm.Get("rated") != "true" {
+			http.Error(w, `{"error":"unexpected form `+r.PostForm.Encode()+`"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w)
+		w.(http.Flusher).Flush()
+		select {
+		case <-accept:
+		case <-r.Context().Done():
+			canceled <- r.PostForm.Get("time")
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{HttpClient: server.Client(), BaseURL: server.URL}
+	params := SeekParams{Rated: true, Time: 150 * time.Second, Increment: time.Second}
+
+	seek, err := c.Seek(params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	close(accept)
+	select {
+	case <-seek.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the seek to end when accepted")
+	}
+	if !seek.Accepted() || seek.Err() != nil {
+		t.Errorf("Expected the seek to be accepted, got %v", seek.Err())
+	}
+
+	accept = make(chan struct{})
+	seek, err = c.Seek(params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	seek.Cancel()
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the server to see the seek canceled")
+	}
+	if seek.Accepted() || seek.Err() != nil {
+		t.Errorf("Expected a canceled seek, got accepted=%v err=%v", seek.Accepted(), seek.Err())
+	}
+
+	if _, err := c.Seek(SeekParams{Time: time.Minute, Increment: 2 * time.Second}); err == nil {
+		t.Errorf("Expected the server error for an unexpected seek")
+	}
+	if _, err := c.Seek(SeekParams{Days: 3}); err == nil {
+		t.Errorf("Expected an error for a real-time seek without time")
+	}
+	if _, err := c.Seek(SeekParams{}); err == nil {
+		t.Errorf("Expected an error for a real-time seek without time or increment")
+	}
+	if _, err := c.Seek(SeekParams{Increment: time.Second}); err == nil {
+		t.Errorf("Expected the server error for an unexpected seek")
+	} else if _, ok := err.(*APIError); !ok {
+		t.Errorf("Expected a 0+1 seek to be sent, got %v", err)
+	}
+
+	id, err := c.SeekCorrespondence(SeekParams{Days: 3, Variant: VariantChess960, Color: "white", RatingMin: 1500, RatingMax: 1800})
+	if err != nil || id != "seek3" {
+		t.Errorf("Unexpected correspondence seek %q (%v)", id, err)
+	}
+	if _, err := c.SeekCorrespondence(params); err == nil {
+		t.Errorf("Expected an error for a correspondence seek without days")
+	}
+}
+
+func TestSeekParams(t *testing.T) {
+	values := SeekParams{Increment: time.Second, RatingMin: 1500}.Values()
+	if values.Get("time") != "0" || values.Get("increment") != "1" {
+		t.Errorf("Unexpected clock %v", values.Encode())
+	}
+	if _, ok := values["ratingRange"]; ok {
+		t.Errorf("Expected no rating range with a single bound, got %q", values.Get("ratingRange"))
+	}
+	values = SeekParams{Time: time.Minute, RatingMin: 1500, RatingMax: 1800}.Values()
+	if values.Get("ratingRange") != "1500-1800" {
+		t.Errorf("Unexpected rating range %q", values.Get("ratingRange"))
+	}
+}
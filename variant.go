@@ -0,0 +1,190 @@
+package main
+
+import "fmt"
+
+const (
+	VariantStandard      = "standard"
+	VariantChess960      = "chess960"
+	VariantFromPosition  = "fromPosition"
+	VariantAntichess     = "antichess"
+	VariantAtomic        = "atomic"
+	VariantCrazyhouse    = "crazyhouse"
+	VariantHorde         = "horde"
+	VariantKingOfTheHill = "kingOfTheHill"
+	VariantRacingKings   = "racingKings"
+	VariantThreeCheck    = "threeCheck"
+)
+
+var variantStartingFens = map[string]string{
+	VariantStandard:      StartingFen,
+	VariantChess960:      StartingFen,
+	VariantFromPosition:  StartingFen,
+	VariantAntichess:     "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+	VariantAtomic:        StartingFen,
+	VariantCrazyhouse:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+	VariantHorde:         "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1",
+	VariantKingOfTheHill: StartingFen,
+	VariantRacingKings:   "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1",
+	VariantThreeCheck:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 3+3 0 1",
+}
+
+var hillSquares = []Square{NewSquare(3, 3), NewSquare(4, 3), NewSquare(3, 4), NewSquare(4, 4)}
+
+func VariantStartingFen(variant string) (string, error) {
+	if variant == "" {
+		variant = VariantStandard
+	}
+	fen, ok := variantStartingFens[variant]
+	if !ok {
+		return "", fmt.Errorf("unsupported variant %q", variant)
+	}
+	return fen, nil
+}
+
+func NewVariantPosition(variant string) (*Position, error) {
+	fen, err := VariantStartingFen(variant)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVariantFen(variant, fen)
+}
+
+type Outcome struct {
+	Status string
+	Winner string
+}
+
+func (o Outcome) Over() bool {
+	return o.Status != ""
+}
+
+func winOutcome(status string, c Color) Outcome {
+	return Outcome{Status: status, Winner: c.String()}
+}
+
+func (p *Position) Outcome() Outcome {
+	if o := p.variantOutcome(); o.Over() {
+		return o
+	}
+	if len(p.generateLegal()) > 0 {
+		return Outcome{}
+	}
+	if p.InCheck() {
+		return winOutcome("mate", p.Turn.Other())
+	}
+	return Outcome{Status: "stalemate"}
+}
+
+func (p *Position) pieceCount(c Color) int {
+	n := 0
+	for _, pc := range p.Board {
+		if pc.Type != NoPieceType && pc.Color == c {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *Position) variantOutcome() Outcome {
+	switch p.Variant {
+	case VariantAtomic:
+		for _, c := range []Color{White, Black} {
+			if p.KingSquare(c) == NoSquare {
+				return winOutcome("variantEnd", c.Other())
+			}
+		}
+	case VariantAntichess:
+		if p.pieceCount(p.Turn) == 0 || len(p.generateLegal()) == 0 {
+			return winOutcome("variantEnd", p.Turn)
+		}
+	case VariantHorde:
+		if p.pieceCount(White) == 0 {
+			return winOutcome("variantEnd", Black)
+		}
+	case VariantKingOfTheHill:
+		for _, sq := range hillSquares {
+			if pc := p.Board[sq]; pc.Type == King {
+				return winOutcome("variantEnd", pc.Color)
+			}
+		}
+	case VariantThreeCheck:
+		for _, c := range []Color{White, Black} {
+			if p.ChecksGiven[c] >= 3 {
+				return winOutcome("variantEnd", c)
+			}
+		}
+	case VariantRacingKings:
+		return p.racingKingsOutcome()
+	}
+	return Outcome{}
+}
+
+func (p *Position) racingKingsOutcome() Outcome {
+	whiteGoal := p.KingSquare(White).Rank() == 7
+	blackGoal := p.KingSquare(Black).Rank() == 7
+	switch {
+	case whiteGoal && blackGoal:
+		return Outcome{Status: "variantEnd"}
+	case blackGoal:
+		return winOutcome("variantEnd", Black)
+	case whiteGoal && p.Turn == White:
+		return winOutcome("variantEnd", White)
+	case whiteGoal:
+		for _, m := range p.generateLegal() {
+			if p.Board[m.From].Type == King && m.To.Rank() == 7 {
+				return Outcome{}
+			}
+		}
+		return winOutcome("variantEnd", White)
+	}
+	return Outcome{}
+}
+
+func (p *Position) kingsAdjacent() bool {
+	a, b := p.KingSquare(White), p.KingSquare(Black)
+	if a == NoSquare || b == NoSquare {
+		return false
+	}
+	df, dr := a.File()-b.File(), a.Rank()-b.Rank()
+	return df >= -1 && df <= 1 && dr >= -1 && dr <= 1
+}
+
+func (p *Position) explode(at Square) {
+	for _, d := range kingSteps {
+		sq := at.step(d)
+		if sq == NoSquare || p.Board[sq].Type == Pawn {
+			continue
+		}
+		p.Board[sq] = NoPiece
+	}
+	p.Board[at] = NoPiece
+}
+
+func (p *Position) pocketString() string {
+	b := make([]byte, 0, 16)
+	for _, c := range []Color{White, Black} {
+		for pt := Queen; pt >= Pawn; pt-- {
+			letter := (Piece{Type: pt, Color: c}).String()
+			for i := 0; i < p.Pockets[c][pt]; i++ {
+				b = append(b, letter...)
+			}
+		}
+	}
+	return string(b)
+}
+
+func (p *Position) dropMoves(moves []Move) []Move {
+	for pt := Pawn; pt <= Queen; pt++ {
+		if p.Pockets[p.Turn][pt] == 0 {
+			continue
+		}
+		for i, pc := range p.Board {
+			sq := Square(i)
+			if pc.Type != NoPieceType || (pt == Pawn && (sq.Rank() == 0 || sq.Rank() == 7)) {
+				continue
+			}
+			moves = append(moves, Move{From: sq, To: sq, Drop: pt})
+		}
+	}
+	return moves
+}
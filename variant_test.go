@@ -0,0 +1,133 @@
+package main
+
+import "testing"
+
+func TestVariantPerft(t *testing.T) {
+	cases := []struct {
+		variant string
+		fen     string
+		nodes   []int
+	}{
+		{VariantAtomic, "", []int{20, 400, 8902, 197326}},
+		{VariantAtomic, "rn2kb1r/1pp1p2p/p2q1pp1/3P4/2P3b1/4PN2/PP3PPP/R2QKB1R b KQkq - 0 1", []int{40, 1238, 45237}},
+		{VariantAntichess, "", []int{20, 400, 8067, 153299}},
+		{VariantHorde, "", []int{8, 128, 1274, 23310}},
+		{VariantRacingKings, "", []int{21, 421, 11264}},
+		{VariantCrazyhouse, "2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", []int{301, 75353}},
+		{VariantThreeCheck, "", []int{20, 400, 8902}},
+		{VariantKingOfTheHill, "", []int{20, 400, 8902}},
+	}
+	for _, tc := range cases {
+		pos, err := NewVariantPosition(tc.variant)
+		if tc.fen != "" {
+			pos, err = ParseVariantFen(tc.variant, tc.fen)
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i, expected := range tc.nodes {
+			if nodes := pos.Perft(i + 1); nodes != expected {
+				t.Errorf("%s Perft(%d): expected %d, got %d", tc.variant, i+1, expected, nodes)
+			}
+		}
+	}
+}
+
+func TestVariantFen(t *testing.T) {
+	fens := map[string]string{
+		VariantCrazyhouse: "r1bqk2r/pppp1ppp/2n5/4p3/1bB1P3/2N2N2/PPPP1PPP/R1B1K2R~[QNnp] w KQkq - 0 6",
+		VariantThreeCheck: "rnbqkbnr/ppp2ppp/8/3pp3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 2+3 0 3",
+		VariantHorde:      "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1",
+	}
+	for variant, fen := range fens {
+		pos, err := ParseVariantFen(variant, fen)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if pos.Fen() != fen {
+			t.Errorf("Expected %s, got %s", fen, pos.Fen())
+		}
+	}
+
+	pos, _ := ParseVariantFen(VariantThreeCheck, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +1+2")
+	if pos.ChecksGiven != [2]int{1, 2} {
+		t.Errorf("Expected checks given from the +1+2 suffix, got %v", pos.ChecksGiven)
+	}
+	if _, err := ParseVariantFen("shogi", StartingFen); err == nil {
+		t.Errorf("Expected an error for an unknown variant")
+	}
+}
+
+func TestVariantReplay(t *testing.T) {
+	cases := []struct {
+		variant string
+		fen     string
+		moves   string
+		last    string
+		outcome Outcome
+	}{
+		{VariantCrazyhouse, "", "e4 d5 exd5 Qxd5 Nc3 Qe6+ Be2 Qxe2+ Ngxe2 P@f3 P@e6 fxe2 exf7+ Kxf7 Qxe2 N@d4", "rnb2bnr/ppp1pkpp/8/8/3n4/2N5/PPPPQPPP/R1B1K2R[QPPbp] w KQ - 1 9", Outcome{}},
+		{VariantAtomic, "", "e4 d5 exd5 Qxd5 Nc3 Qxd2#", "rnb1kbnr/ppp1pppp/8/8/8/8/PPP2PPP/R4BNR w kq - 0 4", Outcome{Status: "variantEnd", Winner: "black"}},
+		{VariantKingOfTheHill, "", "d4 e5 dxe5 a6 Kd2 a5 Kd3 a4 Ke4", "rnbqkbnr/1ppp1ppp/8/4P3/p3K3/8/PPP1PPPP/RNBQ1BNR b kq - 1 5", Outcome{Status: "variantEnd", Winner: "white"}},
+		{VariantThreeCheck, "", "e4 e5 Bc4 Nc6 Bxf7+ Kxf7 Qh5+ g6 Qxg6+", "r1bq1bnr/pppp1k1p/2n3Q1/4p3/4P3/8/PPPP1PPP/RNB1K1NR b KQ - 0+3 0 5", Outcome{Status: "variantEnd", Winner: "white"}},
+		{VariantAntichess, "", "e3 b5 Bxb5 Bb7 Bxd7 Qxd7", "rn2kbnr/pbpqpppp/8/8/8/4P3/PPPP1PPP/RNBQK1NR w - - 0 4", Outcome{}},
+		{VariantRacingKings, "8/8/8/8/8/6K1/8/k7 w - - 0 1", "Kg4 Kb2 Kg5 Kc3 Kg6 Kd4 Kg7 Ke5 Kg8", "6K1/8/8/4k3/8/8/8/8 b - - 9 5", Outcome{Status: "variantEnd", Winner: "white"}},
+		{VariantChess960, "rk5r/8/8/8/8/8/8/RK5R w HAha - 0 1", "O-O O-O-O", "2kr3r/8/8/8/8/8/8/R4RK1 w - - 2 2", Outcome{}},
+	}
+	for _, tc := range cases {
+		plies, err := ReplayVariant(tc.variant, tc.fen, tc.moves)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.variant, err)
+			continue
+		}
+		final := plies[len(plies)-1].Position
+		if final.Fen() != tc.last {
+			t.Errorf("%s: expected %s, got %s", tc.variant, tc.last, final.Fen())
+		}
+		if o := final.Outcome(); o != tc.outcome {
+			t.Errorf("%s: expected outcome %+v, got %+v", tc.variant, tc.outcome, o)
+		}
+	}
+}
+
+func TestVariantRules(t *testing.T) {
+	pos, _ := ParseVariantFen(VariantAntichess, "rnbqkbnr/p1pppppp/8/1p6/4P3/8/PPPP1PPP/RNBQKBNR w - - 0 2")
+	if moves := pos.LegalMoves(); len(moves) != 1 || pos.UCI(moves[0]) != "f1b5" {
+		t.Errorf("Expected the capture Bxb5 to be forced, got %v", moves)
+	}
+	pos, _ = ParseVariantFen(VariantAntichess, "8/8/8/8/8/8/8/4k3 w - - 0 1")
+	if o := pos.Outcome(); o.Winner != "white" {
+		t.Errorf("Expected the side without pieces to win antichess, got %+v", o)
+	}
+
+	pos, _ = ParseVariantFen(VariantAtomic, "8/8/8/8/8/8/3kK3/8 w - - 0 1")
+	if pos.InCheck() || len(pos.LegalMoves()) == 0 {
+		t.Errorf("Adjacent kings should not be in check in atomic")
+	}
+
+	pos, _ = ParseVariantFen(VariantHorde, "4k3/8/8/8/8/8/8/8 w - - 0 1")
+	if o := pos.Outcome(); o.Winner != "black" {
+		t.Errorf("Expected black to win when the horde is gone, got %+v", o)
+	}
+
+	pos, _ = ParseVariantFen(VariantRacingKings, "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1")
+	for _, m := range pos.LegalMoves() {
+		if pos.Play(m).InCheck() {
+			t.Errorf("Move %s gives check in racing kings", pos.UCI(m))
+		}
+	}
+	pos, _ = ParseVariantFen(VariantRacingKings, "6K1/1k6/8/8/8/8/8/8 b - - 0 1")
+	if o := pos.Outcome(); o.Over() {
+		t.Errorf("Black should still get a move to reach the goal, got %+v", o)
+	}
+	m, _ := pos.ParseSAN("Kb8")
+	if o := pos.Play(m).Outcome(); o.Status != "variantEnd" || o.Winner != "" {
+		t.Errorf("Expected a draw when both kings reach the goal, got %+v", o)
+	}
+
+	pos, _ = ParseVariantFen(VariantChess960, "rk5r/8/8/8/8/8/8/RK5R w HAha - 0 1")
+	m, _ = pos.ParseSAN("O-O")
+	if pos.UCI(m) != "b1h1" {
+		t.Errorf("Expected king-takes-rook castling notation in chess960, got %s", pos.UCI(m))
+	}
+}
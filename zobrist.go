@@ -0,0 +1,104 @@
+package main
+
+const (
+	polyglotCastlingOffset = 768
+	polyglotEpOffset       = 772
+	polyglotTurnOffset     = 780
+)
+
+var (
+	pocketKeys   [2][7][17]uint64
+	checkKeys    [2][4]uint64
+	promotedKeys [64]uint64
+)
+
+func init() {
+	seed := uint64(0x5f3759df)
+	next := func() uint64 {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for c := range pocketKeys {
+		for pt := range pocketKeys[c] {
+			for n := range pocketKeys[c][pt] {
+				pocketKeys[c][pt][n] = next()
+			}
+		}
+	}
+	for c := range checkKeys {
+		for n := range checkKeys[c] {
+			checkKeys[c][n] = next()
+		}
+	}
+	for sq := range promotedKeys {
+		promotedKeys[sq] = next()
+	}
+}
+
+func polyglotPieceKey(pc Piece, sq Square) uint64 {
+	kind := 2*int(pc.Type-1) + 1 - int(pc.Color)
+	return polyglotRandom[64*kind+int(sq)]
+}
+
+// PolyglotHash returns the position key used by Polyglot opening books.
+func (p *Position) PolyglotHash() uint64 {
+	var h uint64
+	for i, pc := range p.Board {
+		if pc.Type != NoPieceType {
+			h ^= polyglotPieceKey(pc, Square(i))
+		}
+	}
+	for c := range p.CastlingRooks {
+		for side, rook := range p.CastlingRooks[c] {
+			if rook != NoSquare {
+				h ^= polyglotRandom[polyglotCastlingOffset+2*c+side]
+			}
+		}
+	}
+	if p.EpSquare != NoSquare {
+		for _, df := range []int{-1, 1} {
+			from := p.EpSquare.step(direction{df, -pawnDirection(p.Turn)})
+			if from != NoSquare && p.Board[from] == (Piece{Type: Pawn, Color: p.Turn}) {
+				h ^= polyglotRandom[polyglotEpOffset+p.EpSquare.File()]
+				break
+			}
+		}
+	}
+	if p.Turn == White {
+		h ^= polyglotRandom[polyglotTurnOffset]
+	}
+	return h
+}
+
+// Hash identifies the position for repetition detection. It extends the
+// Polyglot key with crazyhouse pockets and promoted pieces and three-check counters.
+func (p *Position) Hash() uint64 {
+	h := p.PolyglotHash()
+	switch p.Variant {
+	case VariantCrazyhouse:
+		for c := range p.Pockets {
+			for pt, n := range p.Pockets[c] {
+				if n > 16 {
+					n = 16
+				}
+				h ^= pocketKeys[c][pt][n]
+			}
+		}
+		for sq, promoted := range p.Promoted {
+			if promoted {
+				h ^= promotedKeys[sq]
+			}
+		}
+	case VariantThreeCheck:
+		for c, n := range p.ChecksGiven {
+			if n > 3 {
+				n = 3
+			}
+			h ^= checkKeys[c][n]
+		}
+	}
+	return h
+}